@@ -9,6 +9,11 @@ package pacman
 // See: https://hg.mozilla.org/mozilla-central/file/tip/netwerk/base/ProxyAutoConfig.cpp
 // Lincese: https://www.mozilla.org/en-US/foundation/licensing/
 
+// Microsoft IPv6 extensions (`isResolvableEx`, and `getClientVersion`) are
+// based on the Chromium implementation.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/winhttp/ipv6-extensions-to-navigator-auto-config-file-format
+
 var builtinJS = `
 function dnsDomainIs(host, domain) {
   return (
@@ -73,6 +78,15 @@ function isResolvable(host) {
   return ip != null;
 }
 
+function isResolvableEx(host) {
+  var ipList = dnsResolveEx(host);
+  return ipList != "";
+}
+
+function getClientVersion() {
+  return "1.0";
+}
+
 function localHostOrDomainIs(host, hostdom) {
   return host == hostdom || hostdom.lastIndexOf(host + ".", 0) == 0;
 }
@@ -218,9 +232,20 @@ function timeRange() {
   }
 
   var hour = isGMT ? date.getUTCHours() : date.getHours();
+
+  if (isGMT) {
+    date.setFullYear(date.getUTCFullYear());
+    date.setMonth(date.getUTCMonth());
+    date.setDate(date.getUTCDate());
+    date.setHours(date.getUTCHours());
+    date.setMinutes(date.getUTCMinutes());
+    date.setSeconds(date.getUTCSeconds());
+  }
+
+  // Based on the (GMT adjusted) date, so the range is on the same day.
   var date1, date2;
-  date1 = new Date();
-  date2 = new Date();
+  date1 = new Date(date.getTime());
+  date2 = new Date(date.getTime());
 
   if (argc == 1) {
     return hour == arguments[0];
@@ -246,14 +271,6 @@ function timeRange() {
     }
   }
 
-  if (isGMT) {
-    date.setFullYear(date.getUTCFullYear());
-    date.setMonth(date.getUTCMonth());
-    date.setDate(date.getUTCDate());
-    date.setHours(date.getUTCHours());
-    date.setMinutes(date.getUTCMinutes());
-    date.setSeconds(date.getUTCSeconds());
-  }
   return date1 <= date2
     ? date1 <= date && date <= date2
     : date2 >= date || date >= date1;
@@ -5,17 +5,42 @@
 package pacman
 
 import (
+	"bytes"
 	"net"
+	"sort"
+	"strings"
 
 	"github.com/dop251/goja"
 )
 
-var builtinNatives = map[string]func(*goja.Runtime) func(call goja.FunctionCall) goja.Value{
+// Separator of IP address lists used by the Microsoft IPv6 extensions.
+const ipAddressListSeparator = ";"
+
+var builtinNatives = map[string]func(*engine) func(call goja.FunctionCall) goja.Value{
 	"dnsResolve":  dnsResolve,
 	"myIpAddress": myIPAddress,
+
+	// Microsoft IPv6 extensions.
+	//
+	// See: https://docs.microsoft.com/en-us/windows/win32/winhttp/ipv6-extensions-to-navigator-auto-config-file-format
+	"dnsResolveEx":      dnsResolveEx,
+	"isInNetEx":         isInNetEx,
+	"myIpAddressEx":     myIPAddressEx,
+	"sortIpAddressList": sortIPAddressList,
+}
+
+// Joins `ips` in the Microsoft IPv6 extensions list format.
+func joinIPAddresses(ips []net.IP) string {
+	ipsAsString := make([]string, 0, len(ips))
+
+	for _, ip := range ips {
+		ipsAsString = append(ipsAsString, ip.String())
+	}
+
+	return strings.Join(ipsAsString, ipAddressListSeparator)
 }
 
-func dnsResolve(vm *goja.Runtime) func(call goja.FunctionCall) goja.Value {
+func dnsResolve(e *engine) func(call goja.FunctionCall) goja.Value {
 	return func(call goja.FunctionCall) goja.Value {
 		arg := call.Argument(0)
 		if arg == nil || arg.Equals(goja.Undefined()) {
@@ -24,42 +49,105 @@ func dnsResolve(vm *goja.Runtime) func(call goja.FunctionCall) goja.Value {
 
 		host := arg.String()
 
-		ips, err := net.LookupIP(host)
+		ips, err := e.parser.lookupIP(e.ctx, host)
 		if err != nil {
 			return goja.Null()
 		}
 
-		return vm.ToValue(ips[0].String())
+		return e.vm.ToValue(ips[0].String())
 	}
 }
 
-func myIPAddress(vm *goja.Runtime) func(call goja.FunctionCall) goja.Value {
+func myIPAddress(e *engine) func(call goja.FunctionCall) goja.Value {
 	return func(call goja.FunctionCall) goja.Value {
-		ifs, err := net.Interfaces()
-		if err != nil {
+		ips, err := e.parser.myIPAddressSource.IPAddresses(e.ctx)
+		if err != nil || len(ips) == 0 {
 			return goja.Null()
 		}
 
-		for _, ifn := range ifs {
-			if ifn.Flags&net.FlagUp != net.FlagUp {
-				continue
-			}
+		return e.vm.ToValue(ips[0].String())
+	}
+}
 
-			addrs, err := ifn.Addrs()
-			if err != nil {
-				continue
-			}
+// Resolves `host` to all of its IPv4, and IPv6 addresses, separated by `;`.
+// Returns empty string if it fails.
+func dnsResolveEx(e *engine) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		if arg == nil || arg.Equals(goja.Undefined()) {
+			return e.vm.ToValue("")
+		}
+
+		ips, err := e.parser.lookupIP(e.ctx, arg.String())
+		if err != nil {
+			return e.vm.ToValue("")
+		}
+
+		return e.vm.ToValue(joinIPAddresses(ips))
+	}
+}
+
+// Returns all the candidate addresses of the host, separated by `;`. Returns
+// empty string if it fails.
+func myIPAddressEx(e *engine) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		ips, err := e.parser.myIPAddressSource.IPAddresses(e.ctx)
+		if err != nil {
+			return e.vm.ToValue("")
+		}
+
+		return e.vm.ToValue(joinIPAddresses(ips))
+	}
+}
+
+// Checks if the IP address (IPv4, or IPv6) is within the CIDR prefix, e.g.:
+// "198.95.0.0/16", or "3ffe:8311:ffff::/48".
+func isInNetEx(e *engine) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		ip := net.ParseIP(strings.TrimSpace(call.Argument(0).String()))
+		if ip == nil {
+			return e.vm.ToValue(false)
+		}
+
+		_, prefix, err := net.ParseCIDR(strings.TrimSpace(call.Argument(1).String()))
+		if err != nil {
+			return e.vm.ToValue(false)
+		}
+
+		return e.vm.ToValue(prefix.Contains(ip))
+	}
+}
+
+// Sorts a list of IP addresses separated by `;`. IPv6 addresses come first,
+// then IPv4 ones, each in ascending order. Returns false if any is invalid.
+func sortIPAddressList(e *engine) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		if arg == nil || arg.Equals(goja.Undefined()) {
+			return e.vm.ToValue(false)
+		}
 
-			for _, addr := range addrs {
-				ip, ok := addr.(*net.IPNet)
-				if ok && ip.IP.IsGlobalUnicast() {
-					ipstr := ip.IP.String()
+		ips := []net.IP{}
 
-					return vm.ToValue(ipstr)
-				}
+		for _, s := range strings.Split(arg.String(), ipAddressListSeparator) {
+			ip := net.ParseIP(strings.TrimSpace(s))
+			if ip == nil {
+				return e.vm.ToValue(false)
 			}
+
+			ips = append(ips, ip)
 		}
 
-		return goja.Null()
+		sort.SliceStable(ips, func(i, j int) bool {
+			iIsIPv4, jIsIPv4 := ips[i].To4() != nil, ips[j].To4() != nil
+
+			if iIsIPv4 != jIsIPv4 {
+				return jIsIPv4
+			}
+
+			return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0
+		})
+
+		return e.vm.ToValue(joinIPAddresses(ips))
 	}
 }
@@ -0,0 +1,154 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+//////
+// Helpers
+//////
+
+// Evaluates the JS `expression` within a PAC, returning its string value.
+func evalPAC(t *testing.T, expression string, opts ...pacman.Option) string {
+	t.Helper()
+
+	pac, err := pacman.NewWithOptions(fmt.Sprintf(`
+function FindProxyForURL(url, host) {
+  return String(%s);
+}`, expression), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pac.FindProxyForURL("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+//////
+// Test cases
+//////
+
+func TestBuiltinNatives_ipv6Extensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{
+			name:       "isInNetEx - IPv4 in prefix",
+			expression: `isInNetEx("198.95.249.79", "198.95.0.0/16")`,
+			want:       "true",
+		},
+		{
+			name:       "isInNetEx - IPv4 not in prefix",
+			expression: `isInNetEx("198.96.249.79", "198.95.0.0/16")`,
+			want:       "false",
+		},
+		{
+			name:       "isInNetEx - IPv4 /32",
+			expression: `isInNetEx("10.1.2.3", "10.1.2.3/32")`,
+			want:       "true",
+		},
+		{
+			name:       "isInNetEx - IPv6 in prefix",
+			expression: `isInNetEx("3ffe:8311:ffff:abcd:1234:dead:beef:101", "3ffe:8311:ffff::/48")`,
+			want:       "true",
+		},
+		{
+			name:       "isInNetEx - IPv6 not in prefix",
+			expression: `isInNetEx("3ffe:8312:ffff:abcd:1234:dead:beef:101", "3ffe:8311:ffff::/48")`,
+			want:       "false",
+		},
+		{
+			name:       "isInNetEx - IPv4 against IPv6 prefix",
+			expression: `isInNetEx("198.95.249.79", "3ffe:8311:ffff::/48")`,
+			want:       "false",
+		},
+		{
+			name:       "isInNetEx - invalid prefix",
+			expression: `isInNetEx("198.95.249.79", "198.95.0.0")`,
+			want:       "false",
+		},
+		{
+			name:       "isInNetEx - invalid address",
+			expression: `isInNetEx("www.example.com", "198.95.0.0/16")`,
+			want:       "false",
+		},
+		{
+			name:       "sortIpAddressList - IPv6 first",
+			expression: `sortIpAddressList("10.2.3.9;2001:4898:28:3:201:2ff:feea:fc14;::1;127.0.0.1;::9")`,
+			want:       "::1;::9;2001:4898:28:3:201:2ff:feea:fc14;10.2.3.9;127.0.0.1",
+		},
+		{
+			name:       "sortIpAddressList - invalid",
+			expression: `sortIpAddressList("10.2.3.9;invalid")`,
+			want:       "false",
+		},
+		{
+			name:       "sortIpAddressList - empty",
+			expression: `sortIpAddressList("")`,
+			want:       "false",
+		},
+		{
+			name:       "dnsResolveEx - literal",
+			expression: `dnsResolveEx("10.2.3.9")`,
+			want:       "10.2.3.9",
+		},
+		{
+			name:       "isResolvableEx - literal",
+			expression: `isResolvableEx("::1")`,
+			want:       "true",
+		},
+		{
+			name:       "getClientVersion",
+			expression: `getClientVersion()`,
+			want:       "1.0",
+		},
+		{
+			name:       "myIpAddressEx - is a string",
+			expression: `typeof myIpAddressEx()`,
+			want:       "string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evalPAC(t, tt.expression); got != tt.want {
+				t.Fatalf("%s expected %s, got %s", tt.expression, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParser_FindProxyForURLEx_preferred(t *testing.T) {
+	pac, err := pacman.New(`
+function FindProxyForURL(url, host) {
+  return "PROXY 1.2.3.4:8080";
+}
+
+function FindProxyForURLEx(url, host) {
+  return "PROXY 4.5.6.7:8080; DIRECT";
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pac.FindProxyForURL("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r != "PROXY 4.5.6.7:8080; DIRECT" {
+		t.Fatalf("Expected FindProxyForURLEx result, got %s", r)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the current time seen by the PAC (`new Date()`), and by the
+// time-based builtins: `weekdayRange`, `dateRange`, and `timeRange`.
+//
+// Note: Non-GMT forms of the builtins use the process' local time zone.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of ordinary functions as `Clock`.
+type ClockFunc func() time.Time
+
+// Now is the `Clock` interface implementation.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// Context key for the evaluation time override.
+type evaluationTimeKey struct{}
+
+// ContextWithTime returns a copy of `ctx` which overrides the current time seen
+// by the PAC. Useful for dry-runs, e.g.: "what would this PAC return on
+// Saturday at 23:00 GMT?".
+func ContextWithTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, evaluationTimeKey{}, t)
+}
+
+// Returns the evaluation time override from `ctx`, if any.
+func evaluationTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(evaluationTimeKey{}).(time.Time)
+
+	return t, ok
+}
+
+// Current time seen by the engine's PAC evaluation. The context override has
+// precedence over the Parser's clock.
+func (e *engine) now() time.Time {
+	if t, ok := evaluationTimeFromContext(e.ctx); ok {
+		return t
+	}
+
+	return e.parser.clock.Now()
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Local time zone of the tests, UTC+10. Pinned - whatever `TZ` is - so local,
+// and GMT forms of the time-based builtins see different hours, and dates.
+var testZone = time.FixedZone("X", 10*3600)
+
+// Wednesday, 15 June 2022, 14:30:15 - local, and GMT. In the other form, it's
+// Wednesday 04:30:15, and Thursday 00:30:15 respectively.
+var (
+	localClock = pacman.ClockFunc(func() time.Time {
+		return time.Date(2022, time.June, 15, 14, 30, 15, 0, testZone)
+	})
+
+	gmtClock = pacman.ClockFunc(func() time.Time {
+		return time.Date(2022, time.June, 15, 14, 30, 15, 0, time.UTC)
+	})
+)
+
+// Pins the local time zone, before any test runs.
+func TestMain(m *testing.M) {
+	time.Local = testZone
+
+	os.Exit(m.Run())
+}
+
+func TestBuiltinFunctions_timeBased(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		gmt        bool
+		want       string
+	}{
+		// weekdayRange(wd1, [wd2], [gmt]).
+		{name: "weekdayRange - no args", expression: `weekdayRange()`, want: "false"},
+		{name: "weekdayRange - wd1", expression: `weekdayRange("WED")`, want: "true"},
+		{name: "weekdayRange - wd1, no match", expression: `weekdayRange("THU")`, want: "false"},
+		{name: "weekdayRange - wd1, GMT", expression: `weekdayRange("WED", "GMT")`, gmt: true, want: "true"},
+		{name: "weekdayRange - wd1, wd2", expression: `weekdayRange("MON", "FRI")`, want: "true"},
+		{name: "weekdayRange - wd1, wd2, no match", expression: `weekdayRange("SAT", "SUN")`, want: "false"},
+		{name: "weekdayRange - wd1, wd2, ending on day", expression: `weekdayRange("SUN", "WED")`, want: "true"},
+		{name: "weekdayRange - wd1, wd2, wrapping", expression: `weekdayRange("FRI", "MON")`, want: "false"},
+		{name: "weekdayRange - wd1, wd2, GMT", expression: `weekdayRange("MON", "FRI", "GMT")`, gmt: true, want: "true"},
+		{name: "weekdayRange - invalid day", expression: `weekdayRange("XYZ")`, want: "false"},
+
+		// dateRange(<day> | <month> | <year>, ..., [gmt]).
+		{name: "dateRange - no args", expression: `dateRange()`, want: "false"},
+		{name: "dateRange - day", expression: `dateRange(15)`, want: "true"},
+		{name: "dateRange - day, no match", expression: `dateRange(16)`, want: "false"},
+		{name: "dateRange - day, GMT", expression: `dateRange(15, "GMT")`, gmt: true, want: "true"},
+		{name: "dateRange - day1, day2", expression: `dateRange(1, 15)`, want: "true"},
+		{name: "dateRange - day1, day2, no match", expression: `dateRange(16, 31)`, want: "false"},
+		{name: "dateRange - month", expression: `dateRange("JUN")`, want: "true"},
+		{name: "dateRange - month, no match", expression: `dateRange("JUL")`, want: "false"},
+		{name: "dateRange - month, GMT", expression: `dateRange("JUN", "GMT")`, gmt: true, want: "true"},
+		{name: "dateRange - month1, month2", expression: `dateRange("MAY", "JUL")`, want: "true"},
+		{name: "dateRange - month1, month2, no match", expression: `dateRange("JUL", "SEP")`, want: "false"},
+		{name: "dateRange - month1, month2, wrapping", expression: `dateRange("NOV", "FEB")`, want: "false"},
+		{name: "dateRange - year", expression: `dateRange(2022)`, want: "true"},
+		{name: "dateRange - year, no match", expression: `dateRange(2021)`, want: "false"},
+		{name: "dateRange - year1, year2", expression: `dateRange(2021, 2023)`, want: "true"},
+		{name: "dateRange - year1, year2, no match", expression: `dateRange(2019, 2021)`, want: "false"},
+		{name: "dateRange - day1, month1, day2, month2", expression: `dateRange(1, "JUN", 30, "JUN")`, want: "true"},
+		{name: "dateRange - day1, month1, day2, month2, no match", expression: `dateRange(1, "JUL", 31, "AUG")`, want: "false"},
+		{name: "dateRange - month1, year1, month2, year2", expression: `dateRange("JUN", 2022, "JUL", 2022)`, want: "true"},
+		{name: "dateRange - month1, year1, month2, year2, no match", expression: `dateRange("OCT", 2021, "MAR", 2022)`, want: "false"},
+		{name: "dateRange - day1, month1, year1, day2, month2, year2", expression: `dateRange(1, "JUN", 2022, 30, "JUN", 2022)`, want: "true"},
+		{name: "dateRange - day1, month1, year1, day2, month2, year2, no match", expression: `dateRange(1, "JUN", 2021, 30, "JUN", 2021)`, want: "false"},
+		{name: "dateRange - day1, month1, year1, day2, month2, year2, GMT", expression: `dateRange(1, "JUN", 2022, 30, "JUN", 2022, "GMT")`, gmt: true, want: "true"},
+
+		// timeRange(<hour1>, <min1>, <sec1>, <hour2>, <min2>, <sec2>, [gmt]).
+		{name: "timeRange - no args", expression: `timeRange()`, want: "false"},
+		{name: "timeRange - hour", expression: `timeRange(14)`, want: "true"},
+		{name: "timeRange - hour, no match", expression: `timeRange(15)`, want: "false"},
+		{name: "timeRange - hour, GMT", expression: `timeRange(14, "GMT")`, gmt: true, want: "true"},
+		{name: "timeRange - hour1, hour2", expression: `timeRange(9, 17)`, want: "true"},
+		{name: "timeRange - hour1, hour2, no match", expression: `timeRange(15, 17)`, want: "false"},
+		{name: "timeRange - hour1, hour2, GMT", expression: `timeRange(9, 17, "GMT")`, gmt: true, want: "true"},
+		{name: "timeRange - hour1, min1, hour2, min2", expression: `timeRange(14, 30, 14, 31)`, want: "true"},
+		{name: "timeRange - hour1, min1, hour2, min2, no match", expression: `timeRange(14, 31, 15, 0)`, want: "false"},
+		{name: "timeRange - hour1, min1, hour2, min2, wrapping", expression: `timeRange(22, 0, 6, 0)`, want: "false"},
+		{name: "timeRange - hour1, min1, sec1, hour2, min2, sec2", expression: `timeRange(14, 30, 0, 14, 30, 30)`, want: "true"},
+		{name: "timeRange - hour1, min1, sec1, hour2, min2, sec2, no match", expression: `timeRange(14, 30, 20, 14, 30, 59)`, want: "false"},
+		{name: "timeRange - hour1, min1, sec1, hour2, min2, sec2, GMT", expression: `timeRange(14, 0, 0, 15, 0, 0, "GMT")`, gmt: true, want: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clock pacman.Clock = localClock
+			if tt.gmt {
+				clock = gmtClock
+			}
+
+			if got := evalPAC(t, tt.expression, pacman.WithClock(clock)); got != tt.want {
+				t.Fatalf("%s expected %s, got %s", tt.expression, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuiltinFunctions_timeRange_badArguments(t *testing.T) {
+	pac, err := pacman.New(`
+function FindProxyForURL(url, host) {
+  return String(timeRange(1, 2, 3));
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pac.FindProxyForURL("http://www.example.com/"); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestParser_ContextWithTime(t *testing.T) {
+	pac, err := pacman.NewWithOptions(`
+function FindProxyForURL(url, host) {
+  if (weekdayRange("SAT", "SUN", "GMT") || !timeRange(8, 18, "GMT")) {
+    return "DIRECT";
+  }
+  return "PROXY 1.2.3.4:8080";
+}`, pacman.WithClock(gmtClock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "Parser clock - Wednesday 14:30 GMT",
+			ctx:  context.Background(),
+			want: "PROXY 1.2.3.4:8080",
+		},
+		{
+			name: "Override - Saturday 23:00 GMT",
+			ctx:  pacman.ContextWithTime(context.Background(), time.Date(2022, time.June, 18, 23, 0, 0, 0, time.UTC)),
+			want: "DIRECT",
+		},
+		{
+			name: "Override - Monday 23:00 GMT",
+			ctx:  pacman.ContextWithTime(context.Background(), time.Date(2022, time.June, 20, 23, 0, 0, 0, time.UTC)),
+			want: "DIRECT",
+		},
+		{
+			name: "Override - Monday 10:00 GMT",
+			ctx:  pacman.ContextWithTime(context.Background(), time.Date(2022, time.June, 20, 10, 0, 0, 0, time.UTC)),
+			want: "PROXY 1.2.3.4:8080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pac.FindProxyForURLContext(tt.ctx, "http://www.example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
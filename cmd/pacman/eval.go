@@ -0,0 +1,57 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Prints the raw, and parsed PAC result for each URL.
+func runEval(stdout io.Writer, fs *flag.FlagSet, args []string) error {
+	var sf sourceFlags
+
+	sf.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+
+	pac, err := sf.load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	for i, uri := range fs.Args()[1:] {
+		result, err := pac.FindProxyForURL(uri)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+
+		proxies, err := pac.ParseProxy(result)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+
+		fmt.Fprintf(stdout, "URL:     %s\n", uri)
+		fmt.Fprintf(stdout, "Result:  %s\n", result)
+		fmt.Fprintln(stdout, "Proxies:")
+
+		for j, proxy := range proxies {
+			fmt.Fprintf(stdout, "  %d. %s\n", j+1, proxy.Redacted())
+		}
+	}
+
+	return nil
+}
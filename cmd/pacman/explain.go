@@ -0,0 +1,74 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Prints which builtins the PAC called, with which arguments, for each URL.
+func runExplain(stdout io.Writer, fs *flag.FlagSet, args []string) error {
+	var sf sourceFlags
+
+	sf.register(fs)
+
+	asJSON := fs.Bool("json", false, "print the traces as JSON, one per line")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+
+	pac, err := sf.load(fs.Arg(0), pacman.WithTracing())
+	if err != nil {
+		return err
+	}
+
+	for i, uri := range fs.Args()[1:] {
+		_, trace, err := pac.FindProxyForURLTrace(context.Background(), uri)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+
+		if *asJSON {
+			if err := json.NewEncoder(stdout).Encode(trace); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+
+		fmt.Fprintf(stdout, "URL:      %s\n", uri)
+		fmt.Fprintf(stdout, "Result:   %s\n", trace.Result)
+		fmt.Fprintf(stdout, "Duration: %s\n", trace.Duration)
+		fmt.Fprintln(stdout, "Calls:")
+
+		if len(trace.Calls) == 0 {
+			fmt.Fprintln(stdout, "  none")
+		}
+
+		for _, line := range strings.SplitAfter(trace.String(), "\n") {
+			if line != "" {
+				fmt.Fprintf(stdout, "  %s", line)
+			}
+		}
+	}
+
+	return nil
+}
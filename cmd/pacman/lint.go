@@ -0,0 +1,76 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+
+	return nil
+}
+
+// Validates the PAC: it must compile, run, define an entry point, and return a
+// valid result - with known modes - for the sample URLs.
+func runLint(stdout io.Writer, fs *flag.FlagSet, args []string) error {
+	var (
+		sf   sourceFlags
+		urls stringsFlag
+	)
+
+	sf.register(fs)
+	fs.Var(&urls, "url", "sample URL to evaluate, repeatable (default http://www.example.com/, and https://www.example.com/)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	if len(urls) == 0 {
+		urls = stringsFlag{"http://www.example.com/", "https://www.example.com/"}
+	}
+
+	pac, err := sf.load(fs.Arg(0), pacman.WithStrict())
+	if err != nil {
+		fmt.Fprintf(stdout, "error: %s\n", err)
+
+		return errFailed
+	}
+
+	problems := 0
+
+	for _, uri := range urls {
+		if _, err := pac.FindProxy(uri); err != nil {
+			fmt.Fprintf(stdout, "error: %s: %s\n", uri, err)
+
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return errFailed
+	}
+
+	fmt.Fprintln(stdout, "OK")
+
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/saucelabs/pacman/pacmantest"
+)
+
+// Runs the `pacmantest` suites against the PAC.
+func runTest(stdout io.Writer, fs *flag.FlagSet, args []string) error {
+	var sf sourceFlags
+
+	sf.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+
+	pac, err := sf.load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	failed := false
+
+	for _, filename := range fs.Args()[1:] {
+		suite, err := pacmantest.Load(filename)
+		if err != nil {
+			return err
+		}
+
+		report, err := suite.Run(context.Background(), pac)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		fmt.Fprintf(stdout, "%s:\n%s", filename, report)
+
+		failed = failed || len(report.Failed()) > 0
+	}
+
+	if failed {
+		return errFailed
+	}
+
+	return nil
+}
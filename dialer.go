@@ -0,0 +1,432 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ContextDialer dials with a context. `*net.Dialer` satisfies it.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialAttempt is the outcome of dialing through one entry of the proxy list.
+type DialAttempt struct {
+	// Proxy tried. Its mode is `DIRECT` for direct connections.
+	Proxy Proxy
+
+	// Duration of the attempt.
+	Duration time.Duration
+
+	// Err is nil if the attempt succeeded.
+	Err error
+}
+
+// DialResult reports how a `Dialer` reached the target.
+type DialResult struct {
+	// Address of the target.
+	Address string
+
+	// Attempts, in order. If the dial succeeded, the last one is the hop used.
+	Attempts []DialAttempt
+
+	// Proxies is the proxy list walked - e.g. the PAC decision.
+	Proxies []Proxy
+}
+
+// Hop returns the proxy used to reach the target, and true. Returns false if
+// all attempts failed.
+func (r *DialResult) Hop() (Proxy, bool) {
+	if len(r.Attempts) == 0 || r.Attempts[len(r.Attempts)-1].Err != nil {
+		return Proxy{}, false
+	}
+
+	return r.Attempts[len(r.Attempts)-1].Proxy, true
+}
+
+// DialError is returned when all entries of the proxy list failed.
+type DialError struct {
+	Result *DialResult
+}
+
+// Error interface implementation, listing per-hop errors.
+func (e *DialError) Error() string {
+	if len(e.Result.Attempts) == 0 {
+		return fmt.Sprintf("failed to dial %s: no proxy to try", e.Result.Address)
+	}
+
+	errMsgs := make([]string, 0, len(e.Result.Attempts))
+
+	for _, attempt := range e.Result.Attempts {
+		errMsgs = append(errMsgs, fmt.Sprintf("%s: %s", attempt.Proxy.String(), attempt.Err))
+	}
+
+	return fmt.Sprintf("failed to dial %s: %s", e.Result.Address, strings.Join(errMsgs, "; "))
+}
+
+// TargetError is returned when the proxy was reached, and completed the
+// handshake, but failed to connect to the target - e.g. CONNECT answered "502
+// Bad Gateway", or SOCKS "host unreachable". The proxy itself is healthy.
+type TargetError struct {
+	Err error
+}
+
+// Error interface implementation.
+func (e *TargetError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// CONNECT statuses about the target, not the proxy. 403 isn't: it's usually
+// the proxy's own policy, e.g. an ACL denying the client.
+var connectTargetStatuses = map[int]bool{
+	http.StatusNotFound:       true,
+	http.StatusBadGateway:     true,
+	http.StatusGatewayTimeout: true,
+}
+
+// Dialer connects to targets walking a PAC proxy list in order: "PROXY a;
+// PROXY b; DIRECT" means try `a`, then `b`, then directly.
+//
+// Each entry is dialed with a `ProxyDialer`, see it for the supported schemes.
+// Unsupported entries fail, and the next one is tried.
+type Dialer struct {
+	// AttemptTimeout limits each attempt, including the proxy handshake. Zero
+	// means no limit other than the context one.
+	AttemptTimeout time.Duration
+
+	// Forward dials proxies, and direct connections. Default is `net.Dialer`.
+	Forward ContextDialer
+
+	// Health, if set, is reported the outcome of each attempt. Default is the
+	// Parser's one (`WithProxyHealth`), if any.
+	Health *ProxyHealth
+
+	// LocalDNS resolves target hostnames locally, instead of letting proxies
+	// resolve them. See `ProxyDialer.LocalDNS`.
+	LocalDNS bool
+
+	// Parser evaluated by `DialContext` to get the proxy list.
+	Parser *Parser
+
+	// Report, if set, is called with the result of each dial.
+	Report func(result *DialResult)
+
+	// Resolver used when `LocalDNS` is set. Default is the Parser's one, if
+	// any, otherwise `NetResolver`.
+	Resolver Resolver
+
+	// TLSConfig used to connect to `https` proxies. The server name defaults
+	// to the proxy hostname.
+	TLSConfig *tls.Config
+}
+
+func (d *Dialer) health() *ProxyHealth {
+	if d.Health == nil && d.Parser != nil {
+		return d.Parser.proxyHealth
+	}
+
+	return d.Health
+}
+
+func (d *Dialer) resolver() Resolver {
+	if d.Resolver == nil && d.Parser != nil {
+		return d.Parser.resolver
+	}
+
+	return d.Resolver
+}
+
+func (d *Dialer) forward() ContextDialer {
+	if d.Forward == nil {
+		return &net.Dialer{}
+	}
+
+	return d.Forward
+}
+
+// DialContext evaluates the Parser for `address`, and dials it through the
+// resulting proxy list. The PAC sees `https://host/` for port 443, and
+// `http://host:port/` otherwise. It satisfies `ContextDialer`, thus can be
+// used as `http.Transport.DialContext`.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, _, err := d.DialWithResult(ctx, network, address)
+
+	return conn, err
+}
+
+// DialWithResult is like `DialContext`, also returning the result. It's nil if
+// the PAC evaluation failed.
+func (d *Dialer) DialWithResult(ctx context.Context, network, address string) (net.Conn, *DialResult, error) {
+	if d.Parser == nil {
+		return nil, nil, errors.New("pacman: Dialer.Parser is required by DialContext")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uri := &url.URL{Scheme: "http", Host: net.JoinHostPort(host, port), Path: "/"}
+	if port == "443" {
+		// IPv6 literals must be bracketed, even without port.
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		uri = &url.URL{Scheme: "https", Host: host, Path: "/"}
+	}
+
+	proxies, err := d.Parser.FindProxyContext(ctx, uri.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.DialProxies(ctx, network, address, proxies)
+}
+
+// DialProxies dials `address` through `proxies`, in order, until one succeeds.
+// The result reports the hop used, and per-hop errors. If all fail, the error
+// is a `*DialError`.
+func (d *Dialer) DialProxies(ctx context.Context, network, address string, proxies []Proxy) (net.Conn, *DialResult, error) {
+	result := &DialResult{Address: address, Proxies: proxies}
+
+	if d.Report != nil {
+		defer d.Report(result)
+	}
+
+	health := d.health()
+
+	for _, proxy := range proxies {
+		if err := ctx.Err(); err != nil {
+			return nil, result, err
+		}
+
+		start := time.Now()
+
+		conn, err := d.dialProxy(ctx, network, address, proxy)
+
+		result.Attempts = append(result.Attempts, DialAttempt{
+			Duration: time.Since(start),
+			Err:      err,
+			Proxy:    proxy,
+		})
+
+		if health != nil {
+			switch {
+			case err == nil:
+				health.ReportSuccess(proxy)
+			case ctx.Err() == nil && !isTargetError(err):
+				// Proxy's fault, not the caller giving up, nor the target
+				// being unreachable.
+				health.ReportFailure(proxy)
+			}
+		}
+
+		if err == nil {
+			return conn, result, nil
+		}
+	}
+
+	return nil, result, &DialError{Result: result}
+}
+
+// Returns true if `err` is about the target, not the proxy.
+func isTargetError(err error) bool {
+	var targetErr *TargetError
+
+	return errors.As(err, &targetErr)
+}
+
+// Dials `address` through a single `proxy`, within the attempt timeout.
+func (d *Dialer) dialProxy(ctx context.Context, network, address string, proxy Proxy) (net.Conn, error) {
+	if d.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, d.AttemptTimeout)
+		defer cancel()
+	}
+
+	pd := &ProxyDialer{
+		Forward:   d.Forward,
+		LocalDNS:  d.LocalDNS,
+		Proxy:     proxy,
+		Resolver:  d.resolver(),
+		TLSConfig: d.TLSConfig,
+	}
+
+	return pd.DialContext(ctx, network, address)
+}
+
+// Runs `f` - doing I/O on `conn` - honoring `ctx`: its deadline, and its
+// cancellation, which unblocks `f` by setting a past deadline on `conn`. The
+// deadline is cleared afterwards.
+func withDeadline(ctx context.Context, conn net.Conn, f func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// Context can't be done, no need to watch it.
+	if ctx.Done() == nil {
+		return f()
+	}
+
+	done := make(chan struct{})
+	watcherDone := make(chan struct{})
+
+	go func() {
+		defer close(watcherDone)
+
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	err := f()
+
+	close(done)
+
+	// Only clears the deadline once the watcher can't set it anymore.
+	<-watcherDone
+
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// Connection whose first reads are served from a buffered reader.
+type bufferedConn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Dials `address` through the HTTP proxy at `proxyURI`, using CONNECT. `https`
+// proxies are connected to over TLS.
+func dialHTTPConnect(
+	ctx context.Context,
+	forward ContextDialer,
+	proxyURI *url.URL,
+	tlsConfig *tls.Config,
+	address string,
+) (net.Conn, error) {
+	conn, err := forward.DialContext(ctx, "tcp", proxyURI.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(proxyURI.Scheme, "https") {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+
+		if cfg.ServerName == "" {
+			cfg.ServerName = proxyURI.Hostname()
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	var bufferedReader *bufio.Reader
+
+	if err := withDeadline(ctx, conn, func() error {
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: http.Header{},
+		}
+
+		if proxyURI.User != nil {
+			password, _ := proxyURI.User.Password()
+
+			req.Header.Set("Proxy-Authorization", basicAuthHeader(proxyURI.User.Username(), password))
+		}
+
+		if err := req.Write(conn); err != nil {
+			return err
+		}
+
+		bufferedReader = bufio.NewReader(conn)
+
+		resp, err := http.ReadResponse(bufferedReader, req)
+		if err != nil {
+			return err
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+
+			if connectTargetStatuses[resp.StatusCode] {
+				return &TargetError{Err: err}
+			}
+
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	if bufferedReader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: bufferedReader}, nil
+	}
+
+	return conn, nil
+}
+
+// Value of a basic `Authorization`, or `Proxy-Authorization` header.
+func basicAuthHeader(username, password string) string {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(username, password)
+
+	return req.Header.Get("Authorization")
+}
@@ -0,0 +1,349 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+//////
+// Helpers
+//////
+
+// Reads the greeting of the echo server through `conn`.
+func readGreeting(t *testing.T, conn net.Conn, greeting string) {
+	t.Helper()
+
+	buf := make([]byte, len(greeting))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != greeting {
+		t.Fatalf("Expected %q, got %q", greeting, buf)
+	}
+}
+
+// Parses the PAC `result`.
+func mustParseProxies(t *testing.T, result string) []pacman.Proxy {
+	t.Helper()
+
+	pac, err := pacman.New(fmt.Sprintf(`function FindProxyForURL(url, host) { return %q; }`, result))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxies, err := pac.FindProxy("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return proxies
+}
+
+//////
+// Test cases
+//////
+
+func TestDialer_DialProxies(t *testing.T) {
+	target := startEchoServer(t, "hello")
+	dead := closedAddr(t)
+	connectProxy := startConnectProxy(t, "")
+	authConnectProxy := startConnectProxy(t, "Basic dXNlcjpwYXNz")
+	socks5Proxy := startSOCKS5Proxy(t, "", "")
+	authSOCKS5Proxy := startSOCKS5Proxy(t, "user", "pass")
+
+	tests := []struct {
+		name         string
+		result       string
+		wantAttempts int
+		wantHop      string
+	}{
+		{
+			name:         "Should work - HTTP CONNECT",
+			result:       "PROXY " + connectProxy,
+			wantAttempts: 1,
+			wantHop:      "PROXY http://" + connectProxy,
+		},
+		{
+			name:         "Should work - HTTP CONNECT with credentials",
+			result:       "PROXY http://user:pass@" + authConnectProxy,
+			wantAttempts: 1,
+			wantHop:      "PROXY http://user:pass@" + authConnectProxy,
+		},
+		{
+			name:         "Should work - SOCKS5",
+			result:       "SOCKS5 " + socks5Proxy,
+			wantAttempts: 1,
+			wantHop:      "SOCKS5 socks5://" + socks5Proxy,
+		},
+		{
+			name:         "Should work - SOCKS5 with credentials",
+			result:       "SOCKS5 socks5://user:pass@" + authSOCKS5Proxy,
+			wantAttempts: 1,
+			wantHop:      "SOCKS5 socks5://user:pass@" + authSOCKS5Proxy,
+		},
+		{
+			name:         "Should work - failover to the next proxy",
+			result:       "PROXY " + dead + "; PROXY " + authConnectProxy + "; SOCKS5 " + socks5Proxy,
+			wantAttempts: 3,
+			wantHop:      "SOCKS5 socks5://" + socks5Proxy,
+		},
+		{
+			name:         "Should work - failover to DIRECT",
+			result:       "PROXY " + dead + "; SOCKS5 " + authSOCKS5Proxy + "; DIRECT",
+			wantAttempts: 3,
+			wantHop:      "DIRECT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &pacman.Dialer{AttemptTimeout: 2 * time.Second}
+
+			conn, result, err := d.DialProxies(context.Background(), "tcp", target, mustParseProxies(t, tt.result))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			readGreeting(t, conn, "hello")
+
+			if len(result.Attempts) != tt.wantAttempts {
+				t.Fatalf("Expected %d attempts, got %d", tt.wantAttempts, len(result.Attempts))
+			}
+
+			for _, attempt := range result.Attempts[:len(result.Attempts)-1] {
+				if attempt.Err == nil {
+					t.Fatalf("Expected %s attempt to fail, got nil", attempt.Proxy.String())
+				}
+			}
+
+			hop, ok := result.Hop()
+			if !ok {
+				t.Fatal("Expected a hop, got none")
+			}
+
+			if hop.String() != tt.wantHop {
+				t.Fatalf("Expected hop %s, got %s", tt.wantHop, hop.String())
+			}
+		})
+	}
+}
+
+func TestDialer_DialProxies_allFail(t *testing.T) {
+	dead := closedAddr(t)
+	authConnectProxy := startConnectProxy(t, "Basic dXNlcjpwYXNz")
+
+	var reported *pacman.DialResult
+
+	d := &pacman.Dialer{
+		AttemptTimeout: 2 * time.Second,
+		Report: func(result *pacman.DialResult) {
+			reported = result
+		},
+	}
+
+	_, result, err := d.DialProxies(
+		context.Background(),
+		"tcp",
+		startEchoServer(t, "hello"),
+		mustParseProxies(t, "PROXY "+dead+"; PROXY "+authConnectProxy+"; SOCKS "+dead),
+	)
+
+	var dialErr *pacman.DialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("Expected DialError, got %v", err)
+	}
+
+	if len(result.Attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(result.Attempts))
+	}
+
+	for _, attempt := range result.Attempts {
+		if attempt.Err == nil {
+			t.Fatalf("Expected %s attempt to fail, got nil", attempt.Proxy.String())
+		}
+	}
+
+	if _, ok := result.Hop(); ok {
+		t.Fatal("Expected no hop")
+	}
+
+	if reported != result {
+		t.Fatal("Expected result to be reported")
+	}
+}
+
+func TestDialer_DialProxies_attemptTimeout(t *testing.T) {
+	// Accepts, but never answers the CONNECT.
+	blackhole := serve(t, func(conn net.Conn) {
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	d := &pacman.Dialer{AttemptTimeout: 100 * time.Millisecond}
+
+	start := time.Now()
+
+	conn, result, err := d.DialProxies(
+		context.Background(),
+		"tcp",
+		startEchoServer(t, "hello"),
+		mustParseProxies(t, "PROXY "+blackhole+"; DIRECT"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("Expected attempt to time out, took %s", time.Since(start))
+	}
+
+	if !errors.Is(result.Attempts[0].Err, context.DeadlineExceeded) && !isTimeout(result.Attempts[0].Err) {
+		t.Fatalf("Expected timeout, got %v", result.Attempts[0].Err)
+	}
+}
+
+func TestDialer_DialContext(t *testing.T) {
+	target := startEchoServer(t, "hello")
+	connectProxy := startConnectProxy(t, "")
+
+	pac, err := pacman.New(fmt.Sprintf(`
+function FindProxyForURL(url, host) {
+  if (url.substring(0, 6) == "https:") return "DIRECT";
+  return "PROXY %s; DIRECT";
+}`, connectProxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hop pacman.Proxy
+
+	d := &pacman.Dialer{
+		Parser: pac,
+		Report: func(result *pacman.DialResult) {
+			hop, _ = result.Hop()
+		},
+	}
+
+	conn, err := d.DialContext(context.Background(), "tcp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	readGreeting(t, conn, "hello")
+
+	if hop.String() != "PROXY http://"+connectProxy {
+		t.Fatalf("Expected hop %s, got %s", connectProxy, hop.String())
+	}
+}
+
+// Checks if `err` is a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Resolver recording the looked up hosts.
+type recordingResolver struct {
+	hostRecorder
+}
+
+func (r *recordingResolver) LookupIP(_ context.Context, host string) ([]net.IP, error) {
+	r.record(host)
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// Dialer failing every dial.
+type failingDialer struct{}
+
+func (failingDialer) DialContext(_ context.Context, _, address string) (net.Conn, error) {
+	return nil, fmt.Errorf("failed to dial %s", address)
+}
+
+func TestDialer_DialWithResult_url(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{name: "Should work - HTTPS", address: "www.example.com:443", want: "https://www.example.com/"},
+		{name: "Should work - HTTP", address: "www.example.com:8080", want: "http://www.example.com:8080/"},
+		{name: "Should work - IPv6 HTTPS", address: "[::1]:443", want: "https://[::1]/"},
+		{name: "Should work - IPv6 HTTP", address: "[::1]:8080", want: "http://[::1]:8080/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &recordingResolver{}
+
+			// The PAC resolves the URL it's called with, so it's recorded.
+			pac, err := pacman.NewWithOptions(
+				`function FindProxyForURL(url, host) { dnsResolve(url); return "DIRECT"; }`,
+				pacman.WithResolver(resolver),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := &pacman.Dialer{Forward: failingDialer{}, Parser: pac}
+
+			_, result, err := d.DialWithResult(context.Background(), "tcp", tt.address)
+
+			var dialErr *pacman.DialError
+
+			if !errors.As(err, &dialErr) || len(result.Attempts) != 1 {
+				t.Fatalf("Expected a DIRECT attempt, got %v", err)
+			}
+
+			if got := resolver.Last(); got != tt.want {
+				t.Fatalf("Expected URL %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDialer_DialProxies_canceled(t *testing.T) {
+	// Accepts, and never replies.
+	silent := serve(t, func(conn net.Conn) {
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	for _, mode := range []string{"PROXY", "SOCKS", "SOCKS5"} {
+		t.Run("Should work - "+mode, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			start := time.Now()
+
+			d := &pacman.Dialer{}
+
+			_, result, err := d.DialProxies(ctx, "tcp", "www.example.com:443", mustParseProxies(t, mode+" "+silent))
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+
+			if time.Since(start) > 2*time.Second {
+				t.Fatalf("Expected the handshake to be canceled, took %s", time.Since(start))
+			}
+
+			if !errors.Is(result.Attempts[0].Err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got %v", result.Attempts[0].Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/pacman"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrNotFound is returned by `Discover` if no candidate PAC loaded.
+var ErrNotFound = customerror.NewFailedToError("discover the PAC (WPAD)")
+
+// Default DHCP client lease files.
+var defaultLeaseFiles = []string{
+	"/var/lib/dhcp/dhclient*.leases",
+	"/var/lib/dhclient/dhclient*.lease*",
+	"/var/lib/NetworkManager/dhclient-*.lease",
+}
+
+// Resolver configuration, providing the default domain.
+const resolvConf = "/etc/resolv.conf"
+
+var (
+	// Matches DHCP option 252 in dhclient lease files. Known as `wpad` if
+	// declared in the dhclient configuration, as `unknown-252` otherwise. The
+	// value is either quoted, or colon-separated hex.
+	leaseWPADRegex = regexp.MustCompile(`^option\s+(?:wpad|unknown-252)\s+(.+);$`)
+
+	// Matches the domain name in dhclient lease files.
+	leaseDomainRegex = regexp.MustCompile(`^option\s+domain-name\s+"([^"]*)";$`)
+)
+
+// Attempt is a candidate PAC URL tried.
+type Attempt struct {
+	// Err is why the candidate failed.
+	Err error
+
+	// URL of the candidate.
+	URL string
+}
+
+// Error is returned by `Discover` if no candidate PAC loaded. It wraps
+// `ErrNotFound`.
+type Error struct {
+	// Attempts in order.
+	Attempts []Attempt
+}
+
+// Discoverer finds the PAC advertised on the network, as per WPAD.
+type Discoverer struct {
+	dhcpURL    string
+	domain     string
+	httpClient *http.Client
+	leaseFiles []string
+	logger     pacman.Logger
+	parserOpts []pacman.Option
+	resolver   pacman.Resolver
+}
+
+//////
+// Helpers.
+//////
+
+// Decodes a dhclient lease option value: quoted, or colon-separated hex.
+func decodeLeaseValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, `"`) {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `"`)
+		}
+	} else if b, err := hex.DecodeString(strings.ReplaceAll(value, ":", "")); err == nil {
+		value = string(b)
+	}
+
+	// Some DHCP servers NUL-terminate the URL.
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
+
+// Reads DHCP option 252, and the domain name of the last lease of
+// `filename`.
+func readLease(filename string) (wpad, domain string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer f.Close()
+
+	var leaseWPAD, leaseDomain string
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "lease"):
+			leaseWPAD, leaseDomain = "", ""
+
+		case line == "}":
+			wpad, domain = leaseWPAD, leaseDomain
+
+		default:
+			if match := leaseWPADRegex.FindStringSubmatch(line); match != nil {
+				leaseWPAD = decodeLeaseValue(match[1])
+			}
+
+			if match := leaseDomainRegex.FindStringSubmatch(line); match != nil {
+				// May be a list, the first one is the domain.
+				if fields := strings.Fields(match[1]); len(fields) > 0 {
+					leaseDomain = fields[0]
+				}
+			}
+		}
+	}
+
+	return wpad, domain, scanner.Err()
+}
+
+// Reads DHCP option 252, and the domain name from the most recent lease of the
+// lease files matching `patterns`.
+func readLeases(patterns []string) (wpad, domain string) {
+	type leaseFile struct {
+		name    string
+		modTime int64
+	}
+
+	files := []leaseFile{}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			files = append(files, leaseFile{name: match, modTime: info.ModTime().UnixNano()})
+		}
+	}
+
+	// Oldest first, so the most recent lease wins.
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].modTime < files[j].modTime
+	})
+
+	for _, file := range files {
+		fileWPAD, fileDomain, err := readLease(file.name)
+		if err != nil {
+			continue
+		}
+
+		if fileWPAD != "" {
+			wpad = fileWPAD
+		}
+
+		if fileDomain != "" {
+			domain = fileDomain
+		}
+	}
+
+	return wpad, domain
+}
+
+// Returns the domain, or first search domain of the resolver configuration.
+func resolvConfDomain(filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) >= 2 && (fields[0] == "domain" || fields[0] == "search") {
+			return fields[1]
+		}
+	}
+
+	return ""
+}
+
+// Returns the domain of the host FQDN, if any.
+func hostnameDomain() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	if i := strings.Index(hostname, "."); i >= 0 {
+		return hostname[i+1:]
+	}
+
+	return ""
+}
+
+// Returns the `wpad.<domain>` URLs, devolving `domain`, most specific first.
+func dnsCandidates(domain string) []string {
+	domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return nil
+	}
+
+	// Devolution stops at the registrable domain, never reaching public
+	// suffixes, e.g. `wpad.co.uk`.
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil
+	}
+
+	labels := strings.Split(domain, ".")
+	minLabels := strings.Count(registrable, ".") + 1
+
+	candidates := []string{}
+
+	for i := 0; len(labels)-i >= minLabels; i++ {
+		candidates = append(candidates, "http://wpad."+strings.Join(labels[i:], ".")+"/wpad.dat")
+	}
+
+	return candidates
+}
+
+// Returns the HTTP client fetching from `host`, connecting to `ips` - as
+// resolved by the discoverer resolver - instead of resolving it again. Clients
+// whose transport isn't an `*http.Transport` are returned as is.
+func (d *Discoverer) clientFor(host string, ips []net.IP) *http.Client {
+	rt := d.httpClient.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return d.httpClient
+	}
+
+	transport = transport.Clone()
+
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		addressHost, port, err := net.SplitHostPort(address)
+		if err != nil || !strings.EqualFold(addressHost, host) {
+			return dial(ctx, network, address)
+		}
+
+		var lastErr error
+
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+
+			lastErr = err
+		}
+
+		return nil, lastErr
+	}
+
+	c := *d.httpClient
+	c.Transport = transport
+
+	return &c
+}
+
+// Tries loading the PAC from `uri`.
+func (d *Discoverer) try(ctx context.Context, uri string) (*pacman.Parser, error) {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	client := d.httpClient
+
+	// Resolved once, failing fast if the host doesn't exist. The fetch
+	// connects to the resolved addresses.
+	if net.ParseIP(u.Hostname()) == nil {
+		ips, err := d.resolver.LookupIP(ctx, u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+
+		client = d.clientFor(u.Hostname(), ips)
+
+		defer client.CloseIdleConnections()
+	}
+
+	opts := append([]pacman.Option{pacman.WithHTTPClient(client)}, d.parserOpts...)
+
+	p, err := pacman.NewFromURLContext(ctx, uri, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached copy, or the fallback PAC doesn't make a candidate succeed.
+	if staleness, stale := p.Stale(); stale {
+		return nil, staleness.Err
+	}
+
+	return p, nil
+}
+
+//////
+// Exported.
+//////
+
+// Error interface implementation.
+func (e *Error) Error() string {
+	if len(e.Attempts) == 0 {
+		return fmt.Sprintf("%s: no candidate", ErrNotFound)
+	}
+
+	errMsgs := make([]string, 0, len(e.Attempts))
+
+	for _, attempt := range e.Attempts {
+		errMsgs = append(errMsgs, fmt.Sprintf("%s: %s", attempt.URL, attempt.Err))
+	}
+
+	return fmt.Sprintf("%s: %s", ErrNotFound, strings.Join(errMsgs, "; "))
+}
+
+// Unwrap returns `ErrNotFound`.
+func (e *Error) Unwrap() error {
+	return ErrNotFound
+}
+
+// Candidates returns the candidate PAC URLs, in the order they're tried: DHCP
+// option 252, then the DNS candidates.
+func (d *Discoverer) Candidates() []string {
+	dhcpURL, domain := d.dhcpURL, d.domain
+
+	if dhcpURL == "" || domain == "" {
+		leaseWPAD, leaseDomain := readLeases(d.leaseFiles)
+
+		if dhcpURL == "" {
+			dhcpURL = leaseWPAD
+		}
+
+		if domain == "" {
+			domain = leaseDomain
+		}
+	}
+
+	if domain == "" {
+		domain = hostnameDomain()
+	}
+
+	if domain == "" {
+		domain = resolvConfDomain(resolvConf)
+	}
+
+	candidates := []string{}
+
+	if dhcpURL != "" {
+		candidates = append(candidates, dhcpURL)
+	}
+
+	for _, candidate := range dnsCandidates(domain) {
+		if candidate != dhcpURL {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates
+}
+
+// Discover returns a parser of the first candidate PAC which loads. If none
+// does, `*Error` is returned.
+func (d *Discoverer) Discover(ctx context.Context) (*pacman.Parser, error) {
+	discoveryErr := &Error{}
+
+	for _, candidate := range d.Candidates() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p, err := d.try(ctx, candidate)
+		if err != nil {
+			d.logger.Debug("WPAD candidate failed", "url", candidate, "error", err)
+
+			discoveryErr.Attempts = append(discoveryErr.Attempts, Attempt{Err: err, URL: candidate})
+
+			continue
+		}
+
+		d.logger.Debug("PAC discovered", "url", candidate)
+
+		return p, nil
+	}
+
+	return nil, discoveryErr
+}
+
+//////
+// Factory.
+//////
+
+// New returns a `Discoverer`.
+func New(opts ...Option) *Discoverer {
+	d := &Discoverer{
+		httpClient: http.DefaultClient,
+		leaseFiles: defaultLeaseFiles,
+		logger:     pacman.NopLogger(),
+		resolver:   &pacman.NetResolver{},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Discover is like `Discoverer.Discover`.
+func Discover(ctx context.Context, opts ...Option) (*pacman.Parser, error) {
+	return New(opts...).Discover(ctx)
+}
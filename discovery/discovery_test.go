@@ -0,0 +1,272 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+	"github.com/saucelabs/pacman/discovery"
+)
+
+const lease = `lease {
+  interface "eth0";
+  fixed-address 10.0.0.5;
+  option domain-name "old.example.com";
+  option wpad "http://old.example.com/wpad.dat";
+}
+lease {
+  interface "eth0";
+  fixed-address 10.0.0.5;
+  option domain-name "corp.example.com other.example.com";
+  option unknown-252 68:74:74:70:3a:2f:2f:70:61:63:2e:65:78:61:6d:70:6c:65:2e:63:6f:6d:2f:70:72:6f:78:79:2e:70:61:63:00;
+}
+`
+
+// Returns an HTTP client connecting to `ts`, whatever the URL host.
+func clientTo(ts *httptest.Server) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+			},
+		},
+	}
+}
+
+func TestDiscoverer_Candidates(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dhclient.leases")
+
+	if err := os.WriteFile(leaseFile, []byte(lease), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts []discovery.Option
+		want string
+	}{
+		{
+			name: "Should work - devolution",
+			opts: []discovery.Option{discovery.WithDomain("A.b.example.com.")},
+			want: "http://wpad.a.b.example.com/wpad.dat http://wpad.b.example.com/wpad.dat http://wpad.example.com/wpad.dat",
+		},
+		{
+			name: "Should work - no top-level domain",
+			opts: []discovery.Option{discovery.WithDomain("example.com")},
+			want: "http://wpad.example.com/wpad.dat",
+		},
+		{
+			name: "Should work - multi-label public suffix",
+			opts: []discovery.Option{discovery.WithDomain("corp.example.co.uk")},
+			want: "http://wpad.corp.example.co.uk/wpad.dat http://wpad.example.co.uk/wpad.dat",
+		},
+		{
+			name: "Should work - public suffix",
+			opts: []discovery.Option{discovery.WithDomain("co.uk")},
+			want: "",
+		},
+		{
+			name: "Should work - single label",
+			opts: []discovery.Option{discovery.WithDomain("corp")},
+			want: "",
+		},
+		{
+			name: "Should work - DHCP URL",
+			opts: []discovery.Option{
+				discovery.WithDHCPURL("http://pac.example.com/proxy.pac"),
+				discovery.WithDomain("example.com"),
+			},
+			want: "http://pac.example.com/proxy.pac http://wpad.example.com/wpad.dat",
+		},
+		{
+			name: "Should work - lease file",
+			opts: []discovery.Option{discovery.WithLeaseFiles(filepath.Join(filepath.Dir(leaseFile), "*.leases"))},
+			want: "http://pac.example.com/proxy.pac http://wpad.corp.example.com/wpad.dat http://wpad.example.com/wpad.dat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]discovery.Option{discovery.WithLeaseFiles()}, tt.opts...)
+
+			if got := strings.Join(discovery.New(opts...).Candidates(), " "); got != tt.want {
+				t.Fatalf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "wpad.example.com/wpad.dat":
+			_, _ = w.Write([]byte(`function FindProxyForURL(url, host) { return "PROXY wpad.example.com:8080"; }`))
+		case "pac.example.com/proxy.pac":
+			_, _ = w.Write([]byte(`function FindProxyForURL(url, host) { return "PROXY pac.example.com:8080"; }`))
+		case "wpad.b.example.com/wpad.dat":
+			_, _ = w.Write([]byte(`function FindProxyForURL(url, host) {`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	resolver := pacman.StaticResolver{
+		"pac.example.com":    {"127.0.0.1"},
+		"wpad.b.example.com": {"127.0.0.1"},
+		"wpad.example.com":   {"127.0.0.1"},
+		"wpad.c.example.com": {"127.0.0.1"},
+	}
+
+	tests := []struct {
+		name         string
+		opts         []discovery.Option
+		want         string
+		wantAttempts int
+	}{
+		{
+			name: "Should work - DNS",
+			opts: []discovery.Option{discovery.WithDomain("a.b.example.com")},
+			want: "PROXY wpad.example.com:8080",
+		},
+		{
+			name: "Should work - DHCP",
+			opts: []discovery.Option{
+				discovery.WithDHCPURL("http://pac.example.com/proxy.pac"),
+				discovery.WithDomain("a.b.example.com"),
+			},
+			want: "PROXY pac.example.com:8080",
+		},
+		{
+			name: "Should work - DHCP failing, falling back to DNS",
+			opts: []discovery.Option{
+				discovery.WithDHCPURL("http://pac.example.com/missing.pac"),
+				discovery.WithDomain("example.com"),
+			},
+			want: "PROXY wpad.example.com:8080",
+		},
+		{
+			name: "Should work - fallback PAC isn't a success",
+			opts: []discovery.Option{
+				discovery.WithDHCPURL("http://pac.example.com/missing.pac"),
+				discovery.WithDomain("example.com"),
+				discovery.WithParserOptions(pacman.WithEnvVars(false), pacman.WithFallbackDirect()),
+			},
+			want: "PROXY wpad.example.com:8080",
+		},
+		{
+			name:         "Should fail - not found",
+			opts:         []discovery.Option{discovery.WithDomain("a.c.example.org")},
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]discovery.Option{
+				discovery.WithHTTPClient(clientTo(ts)),
+				discovery.WithLeaseFiles(),
+				discovery.WithParserOptions(pacman.WithEnvVars(false)),
+				discovery.WithResolver(resolver),
+			}, tt.opts...)
+
+			pac, err := discovery.Discover(context.Background(), opts...)
+
+			if tt.wantAttempts > 0 {
+				var discoveryErr *discovery.Error
+
+				if !errors.As(err, &discoveryErr) || !errors.Is(err, discovery.ErrNotFound) {
+					t.Fatalf("Expected *discovery.Error, got %v", err)
+				}
+
+				if len(discoveryErr.Attempts) != tt.wantAttempts {
+					t.Fatalf("Expected %d attempts, got %d", tt.wantAttempts, len(discoveryErr.Attempts))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := pac.FindProxyForURL("http://www.example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiscover_resolver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`function FindProxyForURL(url, host) { return "DIRECT"; }`))
+	}))
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// `.test` names never resolve through the system resolver.
+	pac, err := discovery.Discover(context.Background(),
+		discovery.WithDHCPURL("http://pac.example.test:"+port+"/proxy.pac"),
+		discovery.WithDomain("example.test"),
+		discovery.WithHTTPClient(&http.Client{Transport: &http.Transport{}}),
+		discovery.WithLeaseFiles(),
+		discovery.WithParserOptions(pacman.WithEnvVars(false)),
+		discovery.WithResolver(pacman.StaticResolver{"pac.example.test": {"127.0.0.1"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pac.Source(); got != "http://pac.example.test:"+port+"/proxy.pac" {
+		t.Fatalf("Expected the DHCP URL, got %s", got)
+	}
+}
+
+func TestDiscover_canceled(t *testing.T) {
+	// Never answers, until the request is canceled.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := discovery.Discover(ctx,
+		discovery.WithDomain("example.com"),
+		discovery.WithHTTPClient(clientTo(ts)),
+		discovery.WithLeaseFiles(),
+		discovery.WithParserOptions(pacman.WithEnvVars(false), pacman.WithRetries(3, time.Second)),
+		discovery.WithResolver(pacman.StaticResolver{"wpad.example.com": {"127.0.0.1"}}),
+	)
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("Expected the discovery to be canceled, took %s", time.Since(start))
+	}
+}
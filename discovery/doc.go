@@ -0,0 +1,21 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package discovery finds the PAC advertised on the network, as per the Web
+// Proxy Auto-Discovery (WPAD) protocol, and loads it:
+//
+//  1. DHCP option 252 - from a supplied value, or the DHCP client lease files
+//  2. DNS - `http://wpad.<domain>/wpad.dat`, devolving the domain, e.g. for
+//     `a.b.example.com`: `wpad.a.b.example.com`, `wpad.b.example.com`, then
+//     `wpad.example.com`. Top-level domains are never tried.
+//
+// The first candidate which loads is used:
+//
+//	pac, err := discovery.Discover(ctx, discovery.WithParserOptions(
+//		pacman.WithEvaluationTimeout(time.Second),
+//	))
+//
+// The resolver, and the HTTP client are configurable, see `WithResolver`, and
+// `WithHTTPClient`.
+package discovery
@@ -0,0 +1,82 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package discovery
+
+import (
+	"net/http"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Option allows to configure a `Discoverer`.
+type Option func(d *Discoverer)
+
+// WithDHCPURL sets the PAC URL advertised by DHCP (option 252), e.g. as
+// reported by the OS network manager. It has precedence over the lease files.
+func WithDHCPURL(uri string) Option {
+	return func(d *Discoverer) {
+		d.dhcpURL = uri
+	}
+}
+
+// WithLeaseFiles sets the DHCP client lease files (glob patterns) option 252,
+// and the domain name are read from. Only the ISC dhclient format is
+// supported. Default is the dhclient, and NetworkManager (dhclient backend)
+// locations. None disables reading lease files.
+func WithLeaseFiles(patterns ...string) Option {
+	return func(d *Discoverer) {
+		d.leaseFiles = patterns
+	}
+}
+
+// WithDomain sets the domain the DNS candidates are derived from. Default is
+// the DHCP domain name, then the host FQDN, then the `/etc/resolv.conf`
+// domain, or first search domain.
+func WithDomain(domain string) Option {
+	return func(d *Discoverer) {
+		d.domain = domain
+	}
+}
+
+// WithResolver sets the resolver of the candidates hosts. They're fetched
+// from the resolved addresses - unless the HTTP client transport isn't an
+// `*http.Transport`. Default is `pacman.NetResolver`.
+func WithResolver(resolver pacman.Resolver) Option {
+	return func(d *Discoverer) {
+		if resolver != nil {
+			d.resolver = resolver
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client fetching the candidates. Default is
+// `http.DefaultClient`.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Discoverer) {
+		if client != nil {
+			d.httpClient = client
+		}
+	}
+}
+
+// WithParserOptions sets the options of the returned `pacman.Parser`. Loading
+// options overriding the HTTP client (`pacman.WithHTTPClient`,
+// `pacman.WithRoundTripper`) bypass the resolver. Fallback options
+// (`pacman.WithCacheDir`, `pacman.WithFallback`, `pacman.WithFallbackDirect`)
+// don't make a candidate succeed: one which didn't load is a failed attempt.
+func WithParserOptions(opts ...pacman.Option) Option {
+	return func(d *Discoverer) {
+		d.parserOpts = opts
+	}
+}
+
+// WithLogger sets the logger. Default is a no-op one.
+func WithLogger(logger pacman.Logger) Option {
+	return func(d *Discoverer) {
+		if logger != nil {
+			d.logger = logger
+		}
+	}
+}
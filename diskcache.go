@@ -0,0 +1,189 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Content of the PAC used by `WithFallbackDirect`.
+const directPAC = `function FindProxyForURL(url, host) { return "DIRECT"; }`
+
+// Staleness describes a PAC which couldn't be loaded from its source, replaced
+// by the cached copy (see `WithCacheDir`), or the fallback one (see
+// `WithFallback`).
+type Staleness struct {
+	// Err is why loading failed.
+	Err error
+
+	// FetchedAt is when the cached copy in use was fetched. Zero if the
+	// fallback PAC is in use.
+	FetchedAt time.Time
+}
+
+// A remote PAC stored in the cache dir, with its validators.
+type cachedPAC struct {
+	Content      string    `json:"content"`
+	ETag         string    `json:"etag,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	LastModified string    `json:"lastModified,omitempty"`
+	URL          string    `json:"url"`
+}
+
+//////
+// Helpers.
+//////
+
+// Returns `uri` without password.
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	return u.Redacted()
+}
+
+// Returns the cache file of the PAC at `uri`. Credentials aren't part of the
+// name.
+func cacheFilename(dir, uri string) string {
+	sum := sha256.Sum256([]byte(redactURI(uri)))
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Validators of the cached PAC.
+func (c *cachedPAC) validators() validators {
+	return validators{etag: c.ETag, lastModified: c.LastModified}
+}
+
+// Reads the cached copy of the PAC at `uri`. Returns nil if there's none.
+func (p *Parser) readCachedPAC(uri string) *cachedPAC {
+	if p.cacheDir == "" {
+		return nil
+	}
+
+	buf, err := os.ReadFile(cacheFilename(p.cacheDir, uri))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			p.logger.Warn("Failed to read the cached PAC", "url", redactURI(uri), "error", err)
+		}
+
+		return nil
+	}
+
+	cached := &cachedPAC{}
+
+	if err := json.Unmarshal(buf, cached); err != nil {
+		p.logger.Warn("Failed to read the cached PAC", "url", redactURI(uri), "error", err)
+
+		return nil
+	}
+
+	return cached
+}
+
+// Stores the PAC at `uri` in the cache dir, if any. The file is replaced
+// atomically. Failures are logged.
+func (p *Parser) writeCachedPAC(uri, content string, v validators) {
+	if p.cacheDir == "" {
+		return
+	}
+
+	if err := writeCacheFile(p.cacheDir, uri, &cachedPAC{
+		Content:      content,
+		ETag:         v.etag,
+		FetchedAt:    time.Now(),
+		LastModified: v.lastModified,
+		URL:          redactURI(uri),
+	}); err != nil {
+		p.logger.Warn("Failed to cache the PAC", "url", redactURI(uri), "error", err)
+	}
+}
+
+// Writes `cached` to the cache file of `uri`, through a temporary file.
+func writeCacheFile(dir, uri string, cached *cachedPAC) error {
+	buf, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".pac-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), cacheFilename(dir, uri))
+}
+
+// Loads the last-known-good PAC after loading `uri` failed with `err`: the
+// cached copy, then the fallback PAC. Returns `err` if there's none.
+func (p *Parser) fallback(uri string, cached *cachedPAC, err error) error {
+	if cached != nil {
+		if initErr := p.initialize(uri, cached.Content); initErr == nil {
+			p.staleness = &Staleness{Err: err, FetchedAt: cached.FetchedAt}
+
+			p.logger.Warn("Failed to load PAC, using the cached copy",
+				"url", redactURI(uri),
+				"fetchedAt", cached.FetchedAt,
+				"error", err,
+			)
+
+			return nil
+		}
+	}
+
+	if p.fallbackPAC != "" {
+		if initErr := p.initialize("fallback", p.fallbackPAC); initErr != nil {
+			return initErr
+		}
+
+		p.staleness = &Staleness{Err: err}
+
+		p.logger.Warn("Failed to load PAC, using the fallback one", "url", redactURI(uri), "error", err)
+
+		return nil
+	}
+
+	return err
+}
+
+//////
+// Exported.
+//////
+
+// Stale returns whether the PAC in use is the cached copy, or the fallback
+// one - because loading it from its source failed - and why.
+func (p *Parser) Stale() (Staleness, bool) {
+	if p.staleness == nil {
+		return Staleness{}, false
+	}
+
+	return *p.staleness, true
+}
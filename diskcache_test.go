@@ -0,0 +1,139 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+func TestNewWithOptions_WithCacheDir(t *testing.T) {
+	dir := t.TempDir()
+
+	s := &pacServer{}
+	s.set(reloadPAC1, `"v1"`)
+
+	ts := httptest.NewServer(s)
+	url := ts.URL + "/proxy.pac"
+
+	// Fetched, and cached.
+	pac, err := pacman.NewWithOptions(url, pacman.WithCacheDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stale := pac.Stale(); stale {
+		t.Fatal("Expected fresh PAC")
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("Expected 1 cached PAC, got %d (%v)", len(entries), err)
+	}
+
+	// Revalidated.
+	pac, err = pacman.NewWithOptions(url, pacman.WithCacheDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.ifNoneMatch[len(s.ifNoneMatch)-1]; got != `"v1"` {
+		t.Fatalf("Expected conditional request, got If-None-Match %q", got)
+	}
+
+	if _, stale := pac.Stale(); stale {
+		t.Fatal("Expected fresh PAC")
+	}
+
+	assertFindProxyForURL(t, pac, "PROXY 127.0.0.1:8080")
+
+	// Invalid, the cached copy is used.
+	s.set("function FindProxyForURL(url, host) {", `"v2"`)
+
+	pac, err = pacman.NewWithOptions(url, pacman.WithCacheDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if staleness, stale := pac.Stale(); !stale || staleness.Err == nil || staleness.FetchedAt.IsZero() {
+		t.Fatalf("Expected stale PAC, got %+v", staleness)
+	}
+
+	assertFindProxyForURL(t, pac, "PROXY 127.0.0.1:8080")
+
+	// Unreachable, the cached copy is used, over the fallback one.
+	ts.Close()
+
+	pac, err = pacman.NewWithOptions(url, pacman.WithCacheDir(dir), pacman.WithFallbackDirect())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stale := pac.Stale(); !stale {
+		t.Fatal("Expected stale PAC")
+	}
+
+	assertFindProxyForURL(t, pac, "PROXY 127.0.0.1:8080")
+
+	// Not cached.
+	if _, err := pacman.NewWithOptions(url, pacman.WithCacheDir(t.TempDir())); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestNewWithOptions_WithFallback(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		opts    []pacman.Option
+		want    string
+		wantErr bool
+	}{
+		{name: "Should fail - no fallback", wantErr: true},
+		{
+			name: "Should work - fallback",
+			opts: []pacman.Option{pacman.WithFallback(optionsTestPAC)},
+			want: "PROXY 4.5.6.7:8080",
+		},
+		{
+			name: "Should work - DIRECT",
+			opts: []pacman.Option{pacman.WithFallbackDirect()},
+			want: "DIRECT",
+		},
+		{
+			name:    "Should fail - invalid fallback",
+			opts:    []pacman.Option{pacman.WithFallback("function FindProxyForURL(url, host) {")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pac, err := pacman.NewWithOptions(ts.URL, tt.opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if staleness, stale := pac.Stale(); !stale || staleness.Err == nil || !staleness.FetchedAt.IsZero() {
+				t.Fatalf("Expected stale PAC, got %+v", staleness)
+			}
+
+			assertFindProxyForURL(t, pac, tt.want)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/saucelabs/pacman/internal/lru"
+)
+
+// CacheStats are the statistics of a cache.
+type CacheStats struct {
+	// Hits is the number of lookups answered by the cache.
+	Hits uint64 `json:"hits"`
+
+	// Misses is the number of lookups not answered by the cache.
+	Misses uint64 `json:"misses"`
+
+	// Evictions is the number of entries evicted to respect the max size.
+	Evictions uint64 `json:"evictions"`
+
+	// Size is the current number of entries.
+	Size int `json:"size"`
+}
+
+// Converts LRU stats to the exported format.
+func newCacheStats(stats lru.Stats) CacheStats {
+	return CacheStats{
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Evictions: stats.Evictions,
+		Size:      stats.Size,
+	}
+}
+
+// A cached DNS answer. Negative answers have `err` set.
+type dnsCacheEntry struct {
+	err error
+	ips []net.IP
+}
+
+// dnsCache caches answers from a resolver, for all engines of a Parser.
+type dnsCache struct {
+	entries     *lru.Cache[string, dnsCacheEntry]
+	negativeTTL time.Duration
+	ttl         time.Duration
+}
+
+// Looks up `host` in the cache, falling back to `resolver`.
+func (c *dnsCache) lookupIP(ctx context.Context, resolver Resolver, host string) ([]net.IP, error) {
+	if entry, ok := c.entries.Get(host); ok {
+		return entry.ips, entry.err
+	}
+
+	ips, err := resolver.LookupIP(ctx, host)
+	if err == nil {
+		if c.ttl > 0 {
+			c.entries.Set(host, dnsCacheEntry{ips: ips}, c.ttl)
+		}
+
+		return ips, nil
+	}
+
+	// Only "not found" answers are cached. Others, such as timeouts, or a done
+	// context, are transient.
+	var dnsErr *net.DNSError
+	if c.negativeTTL > 0 && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		c.entries.Set(host, dnsCacheEntry{err: err}, c.negativeTTL)
+	}
+
+	return nil, err
+}
+
+func newDNSCache(ttl, negativeTTL time.Duration, maxSize int) *dnsCache {
+	return &dnsCache{
+		entries:     lru.New[string, dnsCacheEntry](maxSize),
+		negativeTTL: negativeTTL,
+		ttl:         ttl,
+	}
+}
+
+//////
+// Exported.
+//////
+
+// DNSCacheStats returns the DNS cache statistics. Returns zero values if the
+// cache isn't enabled (`WithDNSCache`).
+func (p *Parser) DNSCacheStats() CacheStats {
+	if p.dnsCache == nil {
+		return CacheStats{}
+	}
+
+	return newCacheStats(p.dnsCache.entries.Stats())
+}
+
+// ClearDNSCache removes all entries from the DNS cache. It's safe to call it
+// while PAC evaluations are running.
+func (p *Parser) ClearDNSCache() {
+	if p.dnsCache != nil {
+		p.dnsCache.entries.Purge()
+	}
+}
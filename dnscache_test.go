@@ -0,0 +1,144 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+const dnsCacheTestPAC = `
+function FindProxyForURL(url, host) {
+  dnsResolve(host);
+  dnsResolve(host);
+  return isResolvable(host) ? "DIRECT" : "PROXY 1.2.3.4:8080";
+}`
+
+// Resolver counting lookups.
+type countingResolver struct {
+	pacman.Resolver
+
+	lookups int64
+}
+
+func (r *countingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	atomic.AddInt64(&r.lookups, 1)
+
+	return r.Resolver.LookupIP(ctx, host)
+}
+
+func (r *countingResolver) count() int64 {
+	return atomic.LoadInt64(&r.lookups)
+}
+
+func TestParser_WithDNSCache(t *testing.T) {
+	resolver := &countingResolver{Resolver: pacman.StaticResolver{
+		"www.internal.com": {"10.1.2.3"},
+	}}
+
+	pac, err := pacman.NewWithOptions(dnsCacheTestPAC,
+		pacman.WithResolver(resolver),
+		pacman.WithDNSCache(time.Minute, time.Minute, 10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if r, _ := pac.FindProxyForURL("http://www.internal.com/"); r != "DIRECT" {
+			t.Fatalf("Expected DIRECT, got %s", r)
+		}
+
+		if r, _ := pac.FindProxyForURL("http://www.example.com/"); r != "PROXY 1.2.3.4:8080" {
+			t.Fatalf("Expected PROXY, got %s", r)
+		}
+	}
+
+	if resolver.count() != 2 {
+		t.Fatalf("Expected 2 lookups, got %d", resolver.count())
+	}
+
+	stats := pac.DNSCacheStats()
+	if stats.Hits != 16 || stats.Misses != 2 || stats.Size != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	pac.ClearDNSCache()
+
+	if _, err := pac.FindProxyForURL("http://www.internal.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolver.count() != 3 {
+		t.Fatalf("Expected cleared cache to lookup again, got %d lookups", resolver.count())
+	}
+}
+
+func TestParser_WithDNSCache_noNegativeCaching(t *testing.T) {
+	resolver := &countingResolver{Resolver: pacman.StaticResolver{}}
+
+	pac, err := pacman.NewWithOptions(dnsCacheTestPAC,
+		pacman.WithResolver(resolver),
+		pacman.WithDNSCache(time.Minute, 0, 10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pac.FindProxyForURL("http://www.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolver.count() != 3 {
+		t.Fatalf("Expected every lookup to reach the resolver, got %d lookups", resolver.count())
+	}
+}
+
+func TestParser_WithDNSCache_concurrent(t *testing.T) {
+	pac, err := pacman.NewWithOptions(dnsCacheTestPAC,
+		pacman.WithResolver(pacman.StaticResolver{"www.internal.com": {"10.1.2.3"}}),
+		pacman.WithDNSCache(time.Minute, time.Minute, 1),
+		pacman.WithPoolSize(4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				if r, _ := pac.FindProxyForURL("http://www.internal.com/"); r != "DIRECT" {
+					t.Errorf("Expected DIRECT, got %s", r)
+				}
+
+				if r, _ := pac.FindProxyForURL("http://www.example.com/"); r != "PROXY 1.2.3.4:8080" {
+					t.Errorf("Expected PROXY, got %s", r)
+				}
+
+				if j%10 == 0 {
+					pac.ClearDNSCache()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if stats := pac.DNSCacheStats(); stats.Size > 1 || stats.Evictions == 0 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
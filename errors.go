@@ -0,0 +1,38 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TimeoutError is returned when a PAC evaluation is aborted because its
+// context is done, or the evaluation timeout expired.
+type TimeoutError struct {
+	// URL being evaluated.
+	URL string
+
+	// Err is the context error (`context.Canceled`, or
+	// `context.DeadlineExceeded`).
+	Err error
+}
+
+// Error interface implementation.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("PAC evaluation for %s aborted: %s", e.URL, e.Err)
+}
+
+// Unwrap interface implementation returns the context error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the evaluation deadline expired, false if it was
+// canceled.
+func (e *TimeoutError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
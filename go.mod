@@ -11,7 +11,8 @@ require (
 	github.com/go-playground/validator/v10 v10.11.0
 	github.com/saucelabs/customerror v1.0.4
 	github.com/saucelabs/sypl v1.5.13
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/net v0.7.0
+	golang.org/x/text v0.7.0 // indirect
 )
 
 require (
@@ -29,5 +30,5 @@ require (
 	github.com/saucelabs/lumberjack/v3 v3.0.2 // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
-	golang.org/x/sys v0.0.0-20220808155132-1c4a2a72c664 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 )
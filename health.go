@@ -0,0 +1,191 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/pacman/pkg/mode"
+)
+
+const (
+	defaultHealthBaseBackoff = 30 * time.Second
+	defaultHealthMaxBackoff  = 30 * time.Minute
+)
+
+// State of a proxy marked bad.
+type proxyHealthEntry struct {
+	// Consecutive failures.
+	failures int
+
+	// Proxy is bad until then.
+	retryAt time.Time
+}
+
+// ProxyHealth is a registry of bad proxies, shared by parsers, dialers, and
+// callers. As browsers do, a proxy is marked bad when a failure is reported,
+// and retried after a backoff which doubles with each consecutive failure. A
+// success clears it.
+//
+// Proxies are identified by scheme, and host - credentials are ignored.
+// `DIRECT` is never marked bad. The zero value is ready to use. It's safe for
+// concurrent use.
+type ProxyHealth struct {
+	// BaseBackoff is the duration a proxy is bad for, after its first failure.
+	// Default is 30 seconds.
+	BaseBackoff time.Duration
+
+	// Clock provides the current time. Default is the system clock.
+	Clock Clock
+
+	// MaxBackoff caps the backoff. Default is 30 minutes.
+	MaxBackoff time.Duration
+
+	// Skip removes bad proxies from `Order` results, instead of moving them to
+	// the end. If all proxies are bad, they are kept.
+	Skip bool
+
+	entries map[string]*proxyHealthEntry
+	mu      sync.Mutex
+}
+
+// Returns the key identifying `proxy`, empty if it isn't tracked.
+func healthKey(proxy Proxy) string {
+	if proxy.GetMode() == mode.Direct || proxy.GetURI() == nil {
+		return ""
+	}
+
+	return strings.ToLower(proxy.GetURI().Scheme + "://" + proxy.GetURI().Host)
+}
+
+func (h *ProxyHealth) now() time.Time {
+	if h.Clock == nil {
+		return time.Now()
+	}
+
+	return h.Clock.Now()
+}
+
+// Returns the backoff after `failures` consecutive failures.
+func (h *ProxyHealth) backoff(failures int) time.Duration {
+	base := h.BaseBackoff
+	if base <= 0 {
+		base = defaultHealthBaseBackoff
+	}
+
+	max := h.MaxBackoff
+	if max <= 0 {
+		max = defaultHealthMaxBackoff
+	}
+
+	backoff := base
+
+	for i := 1; i < failures && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	if backoff > max {
+		backoff = max
+	}
+
+	return backoff
+}
+
+// ReportFailure marks `proxy` bad, backing off exponentially on consecutive
+// failures.
+func (h *ProxyHealth) ReportFailure(proxy Proxy) {
+	key := healthKey(proxy)
+	if key == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.entries == nil {
+		h.entries = map[string]*proxyHealthEntry{}
+	}
+
+	entry, ok := h.entries[key]
+	if !ok {
+		entry = &proxyHealthEntry{}
+		h.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.retryAt = h.now().Add(h.backoff(entry.failures))
+}
+
+// ReportSuccess clears `proxy` failures.
+func (h *ProxyHealth) ReportSuccess(proxy Proxy) {
+	key := healthKey(proxy)
+	if key == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.entries, key)
+}
+
+// IsBad returns true if `proxy` is currently marked bad - its backoff didn't
+// expire yet.
+func (h *ProxyHealth) IsBad(proxy Proxy) bool {
+	key := healthKey(proxy)
+	if key == "" {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.isBad(key, h.now())
+}
+
+func (h *ProxyHealth) isBad(key string, now time.Time) bool {
+	entry, ok := h.entries[key]
+
+	return ok && now.Before(entry.retryAt)
+}
+
+// Reset clears all failures.
+func (h *ProxyHealth) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = nil
+}
+
+// Order returns `proxies` with the bad ones moved to the end - or removed if
+// `Skip` is set. The relative order is kept, so once a proxy recovers, it's
+// back at its original position.
+func (h *ProxyHealth) Order(proxies []Proxy) []Proxy {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+
+	good := make([]Proxy, 0, len(proxies))
+	bad := []Proxy{}
+
+	for _, proxy := range proxies {
+		if key := healthKey(proxy); key != "" && h.isBad(key, now) {
+			bad = append(bad, proxy)
+
+			continue
+		}
+
+		good = append(good, proxy)
+	}
+
+	if h.Skip && len(good) > 0 {
+		return good
+	}
+
+	return append(good, bad...)
+}
@@ -0,0 +1,272 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+//////
+// Helpers
+//////
+
+// Manually advanced clock.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// Joins `proxies` as a PAC result.
+func joinProxies(proxies []pacman.Proxy) string {
+	s := make([]string, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		s = append(s, proxy.String())
+	}
+
+	return strings.Join(s, "; ")
+}
+
+//////
+// Test cases
+//////
+
+func TestProxyHealth_backoff(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	h := &pacman.ProxyHealth{
+		BaseBackoff: time.Minute,
+		Clock:       clock,
+		MaxBackoff:  3 * time.Minute,
+	}
+
+	proxy := mustParseProxies(t, "PROXY 1.2.3.4:8080")[0]
+
+	// Backoffs: 1m, 2m, 3m (capped).
+	for i, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		h.ReportFailure(proxy)
+
+		clock.Advance(backoff - time.Second)
+
+		if !h.IsBad(proxy) {
+			t.Fatalf("Expected proxy to be bad before %s (failure %d)", backoff, i+1)
+		}
+
+		clock.Advance(time.Second)
+
+		if h.IsBad(proxy) {
+			t.Fatalf("Expected proxy to be retried after %s (failure %d)", backoff, i+1)
+		}
+	}
+
+	h.ReportSuccess(proxy)
+	h.ReportFailure(proxy)
+	clock.Advance(time.Minute)
+
+	if h.IsBad(proxy) {
+		t.Fatal("Expected success to reset the backoff")
+	}
+
+	direct := mustParseProxies(t, "DIRECT")[0]
+
+	h.ReportFailure(direct)
+
+	if h.IsBad(direct) {
+		t.Fatal("Expected DIRECT to never be bad")
+	}
+}
+
+func TestProxyHealth_Order(t *testing.T) {
+	proxies := mustParseProxies(t, "PROXY 1.1.1.1:8080; PROXY 2.2.2.2:8080; SOCKS5 3.3.3.3:1080; DIRECT")
+
+	tests := []struct {
+		name string
+		skip bool
+		bad  []int
+		want string
+	}{
+		{
+			name: "Should work - no bad proxy",
+			want: "PROXY http://1.1.1.1:8080; PROXY http://2.2.2.2:8080; SOCKS5 socks5://3.3.3.3:1080; DIRECT",
+		},
+		{
+			name: "Should work - bad proxies moved to the end",
+			bad:  []int{0, 2},
+			want: "PROXY http://2.2.2.2:8080; DIRECT; PROXY http://1.1.1.1:8080; SOCKS5 socks5://3.3.3.3:1080",
+		},
+		{
+			name: "Should work - bad proxies skipped",
+			skip: true,
+			bad:  []int{0, 2},
+			want: "PROXY http://2.2.2.2:8080; DIRECT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &pacman.ProxyHealth{Skip: tt.skip}
+
+			for _, i := range tt.bad {
+				h.ReportFailure(proxies[i])
+			}
+
+			if got := joinProxies(h.Order(proxies)); got != tt.want {
+				t.Fatalf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Should work - all bad proxies kept when skipping", func(t *testing.T) {
+		h := &pacman.ProxyHealth{Skip: true}
+
+		allProxies := mustParseProxies(t, "PROXY 1.1.1.1:8080; PROXY 2.2.2.2:8080")
+
+		for _, proxy := range allProxies {
+			h.ReportFailure(proxy)
+		}
+
+		if got, want := joinProxies(h.Order(allProxies)), joinProxies(allProxies); got != want {
+			t.Fatalf("Expected %s, got %s", want, got)
+		}
+	})
+}
+
+func TestParser_FindProxy_proxyHealth(t *testing.T) {
+	target := startEchoServer(t, "hello")
+	dead := closedAddr(t)
+	connectProxy := startConnectProxy(t, "")
+
+	clock := &fakeClock{now: time.Now()}
+	health := &pacman.ProxyHealth{Clock: clock}
+
+	pac, err := pacman.NewWithOptions(fmt.Sprintf(`
+function FindProxyForURL(url, host) {
+  return "PROXY %s; PROXY %s";
+}`, dead, connectProxy), pacman.WithProxyHealth(health), pacman.WithResultCache(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := fmt.Sprintf("PROXY http://%s; PROXY http://%s", dead, connectProxy)
+
+	d := &pacman.Dialer{Parser: pac}
+
+	// The dialer fails over, and reports the dead proxy.
+	conn, err := d.DialContext(context.Background(), "tcp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+
+	proxies, err := pac.FindProxy("http://" + target + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := fmt.Sprintf("PROXY http://%s; PROXY http://%s", connectProxy, dead); joinProxies(proxies) != want {
+		t.Fatalf("Expected %s, got %s", want, joinProxies(proxies))
+	}
+
+	// Once the backoff expires, the original order is back.
+	clock.Advance(time.Minute)
+
+	proxies, err = pac.FindProxy("http://" + target + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if joinProxies(proxies) != original {
+		t.Fatalf("Expected %s, got %s", original, joinProxies(proxies))
+	}
+}
+
+func TestDialer_DialProxies_proxyHealthTargetError(t *testing.T) {
+	dead := closedAddr(t)
+
+	badGateway := startFailingConnectProxy(t, "502 Bad Gateway")
+	forbidden := startFailingConnectProxy(t, "403 Forbidden")
+
+	// Answers the SOCKS5 connect with "host unreachable".
+	hostUnreachable := serve(t, func(conn net.Conn) {
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(conn, greeting); err != nil {
+			return
+		}
+
+		if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+			return
+		}
+
+		_, _ = conn.Write([]byte{0x05, 0x00})
+
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		// Domain name, and port.
+		if _, err := io.ReadFull(conn, make([]byte, int(header[4])+2)); err != nil {
+			return
+		}
+
+		_, _ = conn.Write([]byte{0x05, 0x04, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	})
+
+	tests := []struct {
+		name    string
+		result  string
+		wantBad bool
+	}{
+		{name: "Should work - unreachable proxy", result: "PROXY " + dead, wantBad: true},
+		{name: "Should work - CONNECT 502", result: "PROXY " + badGateway},
+		{name: "Should work - CONNECT 403", result: "PROXY " + forbidden, wantBad: true},
+		{name: "Should work - SOCKS5 host unreachable", result: "SOCKS5 " + hostUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := &pacman.ProxyHealth{}
+
+			pac, err := pacman.NewFromText(fmt.Sprintf(`function FindProxyForURL(url, host) { return %q; }`, tt.result),
+				pacman.WithProxyHealth(health),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := &pacman.Dialer{Parser: pac}
+
+			_, result, err := d.DialWithResult(context.Background(), "tcp", "www.example.com:443")
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+
+			var targetErr *pacman.TargetError
+			if isTarget := errors.As(result.Attempts[0].Err, &targetErr); isTarget == tt.wantBad {
+				t.Fatalf("Expected target error %v, got %v", !tt.wantBad, result.Attempts[0].Err)
+			}
+
+			if got := health.IsBad(result.Proxies[0]); got != tt.wantBad {
+				t.Fatalf("Expected bad %v, got %v", tt.wantBad, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = time.Minute
+	defaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// ProxyStatus is the outcome of the last check of a proxy.
+type ProxyStatus struct {
+	// CheckedAt is the time of the check.
+	CheckedAt time.Time
+
+	// Err is why the check failed, nil if it succeeded. A `*TargetError` -
+	// the proxy answered, but failed to reach `Target` - doesn't make the proxy
+	// unhealthy.
+	Err error
+
+	// Latency of the check.
+	Latency time.Duration
+
+	// Proxy checked.
+	Proxy Proxy
+}
+
+// Healthy returns true if the check succeeded, or only the target failed.
+func (s ProxyStatus) Healthy() bool {
+	return s.Err == nil || isTargetError(s.Err)
+}
+
+// HealthChecker actively checks the proxies statically found in the PAC (see
+// `Parser.Proxies`). Each proxy is TCP-connected to, or - if `Target` is set -
+// asked to tunnel to it (HTTP CONNECT, SOCKS5 handshake), using the proxies
+// credentials.
+type HealthChecker struct {
+	// Forward dials proxies. Default is `net.Dialer`.
+	Forward ContextDialer
+
+	// Health, if set, is reported the outcome of each check.
+	Health *ProxyHealth
+
+	// Interval between checks. Default is 1 minute.
+	Interval time.Duration
+
+	// OnChange, if set, is called when a proxy is checked for the first time,
+	// and when it becomes healthy, or unhealthy.
+	OnChange func(status ProxyStatus)
+
+	// Parser whose proxies are checked.
+	Parser *Parser
+
+	// Target (`host:port`), if set, proxies are asked to tunnel to.
+	Target string
+
+	// Timeout of each check. Default is 5 seconds.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	statuses map[string]ProxyStatus
+}
+
+// Checks `proxy`, returning its status.
+func (c *HealthChecker) check(ctx context.Context, proxy Proxy) ProxyStatus {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	d := &Dialer{Forward: c.Forward}
+
+	start := time.Now()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if c.Target != "" {
+		conn, err = d.dialProxy(ctx, "tcp", c.Target, proxy)
+	} else {
+		conn, err = d.forward().DialContext(ctx, "tcp", proxy.GetURI().Host)
+	}
+
+	if err == nil {
+		conn.Close()
+	}
+
+	return ProxyStatus{
+		CheckedAt: start,
+		Err:       err,
+		Latency:   time.Since(start),
+		Proxy:     proxy,
+	}
+}
+
+// Check checks, concurrently, all proxies once, returning their status in PAC
+// order.
+func (c *HealthChecker) Check(ctx context.Context) ([]ProxyStatus, error) {
+	if c.Parser == nil {
+		return nil, errors.New("pacman: HealthChecker.Parser is required")
+	}
+
+	proxies := c.Parser.Proxies()
+	statuses := make([]ProxyStatus, len(proxies))
+
+	var wg sync.WaitGroup
+
+	for i, proxy := range proxies {
+		wg.Add(1)
+
+		go func(i int, proxy Proxy) {
+			defer wg.Done()
+
+			statuses[i] = c.check(ctx, proxy)
+		}(i, proxy)
+	}
+
+	wg.Wait()
+
+	// The caller gave up, results are meaningless.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		c.update(status)
+	}
+
+	return statuses, nil
+}
+
+// Records `status`, reporting it.
+func (c *HealthChecker) update(status ProxyStatus) {
+	key := healthKey(status.Proxy)
+
+	c.mu.Lock()
+
+	if c.statuses == nil {
+		c.statuses = map[string]ProxyStatus{}
+	}
+
+	previous, ok := c.statuses[key]
+	c.statuses[key] = status
+
+	c.mu.Unlock()
+
+	if c.Health != nil {
+		if status.Healthy() {
+			c.Health.ReportSuccess(status.Proxy)
+		} else {
+			c.Health.ReportFailure(status.Proxy)
+		}
+	}
+
+	if c.OnChange != nil && (!ok || previous.Healthy() != status.Healthy()) {
+		c.OnChange(status)
+	}
+}
+
+// Run checks all proxies every `Interval`, until `ctx` is done. The first
+// check is immediate.
+func (c *HealthChecker) Run(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Check(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the last status of the checked proxies, in PAC order.
+func (c *HealthChecker) Status() []ProxyStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := []ProxyStatus{}
+
+	if c.Parser == nil {
+		return statuses
+	}
+
+	for _, proxy := range c.Parser.Proxies() {
+		if status, ok := c.statuses[healthKey(proxy)]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matches the time-based builtins, direct use of `Date`, and dynamic code, or
+// global object access - which may reach them, e.g. `this["Da"+"te"]`.
+var timeBuiltinsRegex = regexp.MustCompile(`\b(weekdayRange|dateRange|timeRange|Date|eval|Function|globalThis)\b|\bthis\s*\[`)
+
+// Statically checks if the PAC `content` depends on the current time. It's
+// best-effort: false positives (e.g. in comments) are possible, and so are
+// false negatives for PACs reaching the time by other, obfuscated, means.
+func usesTimeBuiltins(content string) bool {
+	return timeBuiltinsRegex.MatchString(content)
+}
+
+// Matches JS string literals, single, or double quoted.
+var stringLiteralRegex = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'`)
+
+// Matches a complete proxy entry, e.g.: `PROXY host:8080`.
+var proxyEntryRegex = regexp.MustCompile(`(?i)^\s*(PROXY|HTTPS?|SOCKS[45]?)\s+\S+:\d+\s*$`)
+
+// Statically extracts the proxy entries found in the string literals of the PAC
+// `content`, in order of appearance, deduplicated. Entries built at runtime,
+// e.g. by concatenation, can't be found.
+func proxyLiterals(content string) []string {
+	entries := []string{}
+	seen := map[string]bool{}
+
+	for _, literal := range stringLiteralRegex.FindAllString(content, -1) {
+		for _, entry := range strings.Split(literal[1:len(literal)-1], ";") {
+			entry = strings.TrimSpace(entry)
+
+			if !proxyEntryRegex.MatchString(entry) || seen[entry] {
+				continue
+			}
+
+			seen[entry] = true
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
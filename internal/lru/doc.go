@@ -0,0 +1,7 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package lru provides a concurrent-safe, size-bounded LRU cache with
+// per-entry expiration.
+package lru
@@ -0,0 +1,170 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats of the cache.
+type Stats struct {
+	// Hits is the number of lookups which found a non-expired entry.
+	Hits uint64 `json:"hits"`
+
+	// Misses is the number of lookups which found no, or an expired entry.
+	Misses uint64 `json:"misses"`
+
+	// Evictions is the number of entries evicted to respect the max size.
+	Evictions uint64 `json:"evictions"`
+
+	// Size is the current number of entries, including expired ones not yet
+	// evicted.
+	Size int `json:"size"`
+}
+
+type entry[K comparable, V any] struct {
+	expiresAt time.Time
+	key       K
+	value     V
+}
+
+// Cache is a concurrent-safe LRU cache. Once `maxSize` is reached, the least
+// recently used entry is evicted.
+type Cache[K comparable, V any] struct {
+	mu sync.Mutex
+
+	items   map[K]*list.Element
+	maxSize int
+	now     func() time.Time
+	order   *list.List
+	stats   Stats
+}
+
+// Get returns the value of `key`, if present, and not expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	element, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+
+		return zero, false
+	}
+
+	e := element.Value.(*entry[K, V])
+
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		c.removeElement(element)
+		c.stats.Misses++
+
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+
+	return e.value, true
+}
+
+// Set stores `value` for `key`, expiring after `ttl`. A `ttl` lower, or equal
+// to zero means the entry never expires.
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.expiresAt = expiresAt
+		e.value = value
+
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{
+		expiresAt: expiresAt,
+		key:       key,
+		value:     value,
+	})
+
+	for c.maxSize > 0 && c.order.Len() > c.maxSize {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete removes `key`. Returns true if it was present.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if ok {
+		c.removeElement(element)
+	}
+
+	return ok
+}
+
+// Purge removes all entries. Stats are kept.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Len returns the number of entries, including expired ones not yet evicted.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Stats returns the cache statistics.
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+
+	return stats
+}
+
+// Should be called with the lock held.
+func (c *Cache[K, V]) removeElement(element *list.Element) {
+	e := c.order.Remove(element).(*entry[K, V])
+
+	delete(c.items, e.key)
+}
+
+//////
+// Factory.
+//////
+
+// New is the Cache factory. A `maxSize` lower, or equal to zero means unbound.
+func New[K comparable, V any](maxSize int) *Cache[K, V] {
+	return &Cache[K, V]{
+		items:   make(map[K]*list.Element),
+		maxSize: maxSize,
+		now:     time.Now,
+		order:   list.New(),
+	}
+}
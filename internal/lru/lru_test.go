@@ -0,0 +1,120 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_eviction(t *testing.T) {
+	c := New[string, int](2)
+
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+
+	// "a" is now the most recently used.
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected a=1, got %v %v", v, ok)
+	}
+
+	c.Set("c", 3, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Expected b to be evicted")
+	}
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be kept")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestCache_expiration(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := New[string, int](0)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, 0)
+
+	now = now.Add(59 * time.Second)
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be present")
+	}
+
+	now = now.Add(time.Second)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Expected a to be expired")
+	}
+
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("Expected b to never expire")
+	}
+
+	if c.Len() != 1 {
+		t.Fatalf("Expected expired entry to be removed, got %d entries", c.Len())
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	c := New[string, int](0)
+
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), i, 0)
+	}
+
+	if !c.Delete("0") || c.Delete("0") {
+		t.Fatal("Expected Delete to report presence")
+	}
+
+	if c.Len() != 9 {
+		t.Fatalf("Expected 9 entries, got %d", c.Len())
+	}
+
+	c.Purge()
+
+	if c.Len() != 0 {
+		t.Fatalf("Expected empty cache, got %d entries", c.Len())
+	}
+}
+
+func TestCache_concurrent(t *testing.T) {
+	c := New[int, int](16)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				c.Set(j%32, i, time.Minute)
+				c.Get((j + i) % 32)
+
+				if j%100 == 0 {
+					c.Purge()
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if c.Len() > 16 {
+		t.Fatalf("Expected at most 16 entries, got %d", c.Len())
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/pacman/internal/credential"
+	"github.com/saucelabs/pacman/internal/validation"
+)
+
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// HTTP validators of a remote PAC, for conditional requests.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+//////
+// Helpers.
+//////
+
+// Returns the HTTP client loading remote PACs: `httpClient`, with the
+// `roundTripper`, root CAs, and client certificates applied. `owned` is true if
+// a transport was created for it, then its idle connections should be closed
+// once done.
+func (p *Parser) loaderClient() (client *http.Client, owned bool, err error) {
+	if p.roundTripper == nil && p.rootCAs == nil && p.clientCertificates == nil {
+		return p.httpClient, false, nil
+	}
+
+	c := *p.httpClient
+
+	if p.roundTripper != nil {
+		c.Transport = p.roundTripper
+	}
+
+	if p.rootCAs == nil && p.clientCertificates == nil {
+		return &c, false, nil
+	}
+
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, false, customerror.NewInvalidError(
+			fmt.Sprintf("PAC loader transport (%T), root CAs, and client certificates require an `*http.Transport`", rt),
+		)
+	}
+
+	transport = transport.Clone()
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if p.rootCAs != nil {
+		transport.TLSClientConfig.RootCAs = p.rootCAs
+	}
+
+	if p.clientCertificates != nil {
+		transport.TLSClientConfig.Certificates = p.clientCertificates
+	}
+
+	c.Transport = transport
+
+	return &c, true, nil
+}
+
+// Fetches the PAC at `u` once. `retry` is true if the request failed, and is
+// worth retrying: network errors, and 5xx.
+func (p *Parser) fetchURLOnce(
+	ctx context.Context,
+	client *http.Client,
+	u *url.URL,
+	v validators,
+) (content string, newV validators, notModified, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", v, false, false, err
+	}
+
+	for name, values := range p.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+
+	if u.User != nil {
+		if err := validation.Get().Var(u.User.String(), "basicAuth"); err != nil {
+			return "", v, false, false, customerror.NewInvalidError("PAC URI credential", customerror.WithError(err))
+		}
+
+		password, _ := u.User.Password()
+
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+
+	if p.pacCredential != nil {
+		c, err := credential.NewBasicAuth(p.pacCredential.Username, p.pacCredential.Password)
+		if err != nil {
+			return "", v, false, false, err
+		}
+
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	if err := p.setCredentialFromEnvVar("PACMAN_AUTH", u, req); err != nil {
+		return "", v, false, false, err
+	}
+
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", v, false, true, err
+	}
+
+	defer resp.Body.Close()
+
+	statusCode := resp.StatusCode
+
+	if statusCode == http.StatusNotModified && v != (validators{}) {
+		return "", v, true, false, nil
+	}
+
+	// Checks if request succeeded.
+	if statusCode != http.StatusOK {
+		// Check if status code is valid, otherwise fallback to `500`.
+		if statusCode < http.StatusContinue ||
+			statusCode > http.StatusNetworkAuthenticationRequired {
+			statusCode = http.StatusInternalServerError
+		}
+
+		errMsg := fmt.Sprintf("failed to retrieve PAC content from %s", u.Redacted())
+
+		return "", v, false, statusCode >= http.StatusInternalServerError,
+			customerror.New(errMsg, customerror.WithStatusCode(statusCode), customerror.WithError(err))
+	}
+
+	// Should only read body if request succeeded.
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", v, false, true, err
+	}
+
+	newV = validators{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	return string(buf), newV, false, false, nil
+}
+
+// Fetches the PAC at `uri`, retrying with backoff as configured. The request is
+// conditional if `v` is set, then `notModified` is true if the PAC didn't
+// change.
+func (p *Parser) fetchURL(ctx context.Context, uri string, v validators) (content string, newV validators, notModified bool, err error) {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return "", v, false, err
+	}
+
+	client, owned, err := p.loaderClient()
+	if err != nil {
+		return "", v, false, err
+	}
+
+	if owned {
+		defer client.CloseIdleConnections()
+	}
+
+	backoff := p.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		content, newV, notModified, retry, err := p.fetchURLOnce(ctx, client, u, v)
+		if err == nil || !retry || attempt >= p.retries || ctx.Err() != nil {
+			return content, newV, notModified, err
+		}
+
+		p.logger.Debug("Retrying PAC request",
+			"url", u.Redacted(),
+			"attempt", attempt+1,
+			"backoff", backoff,
+			"error", err,
+		)
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return "", v, false, err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
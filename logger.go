@@ -0,0 +1,79 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"fmt"
+
+	"github.com/saucelabs/sypl"
+	"github.com/saucelabs/sypl/fields"
+	"github.com/saucelabs/sypl/level"
+	"github.com/saucelabs/sypl/options"
+)
+
+// Logger is the minimal logging interface used by pacman. `args` are
+// alternating keys, and values, as in `log/slog`. `*slog.Logger` satisfies it.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+//////
+// No-op.
+//////
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+
+// NopLogger returns a `Logger` which discards everything. It's the default.
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+//////
+// Sypl adapter.
+//////
+
+type syplLogger struct {
+	s *sypl.Sypl
+}
+
+// Converts alternating keys, and values to sypl fields. A key without value is
+// stored under `!BADKEY`, as in `log/slog`.
+func argsToFields(args []any) fields.Fields {
+	f := fields.Fields{}
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			f["!BADKEY"] = args[i]
+
+			break
+		}
+
+		f[fmt.Sprint(args[i])] = args[i+1]
+	}
+
+	return f
+}
+
+func (l *syplLogger) log(lvl level.Level, msg string, args []any) {
+	l.s.PrintlnWithOptions(&options.Options{Fields: argsToFields(args)}, lvl, msg)
+}
+
+func (l *syplLogger) Debug(msg string, args ...any) { l.log(level.Debug, msg, args) }
+func (l *syplLogger) Info(msg string, args ...any)  { l.log(level.Info, msg, args) }
+func (l *syplLogger) Warn(msg string, args ...any)  { l.log(level.Warn, msg, args) }
+func (l *syplLogger) Error(msg string, args ...any) { l.log(level.Error, msg, args) }
+
+// NewSyplLogger adapts a sypl logger to `Logger`.
+func NewSyplLogger(s *sypl.Sypl) Logger {
+	return &syplLogger{s: s}
+}
@@ -0,0 +1,19 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package pacman
+
+import "log/slog"
+
+// NewSlogLogger adapts a `log/slog` logger to `Logger`. If `logger` is nil,
+// `slog.Default()` is used.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+
+	return logger
+}
@@ -0,0 +1,35 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package pacman_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	pac, err := pacman.NewWithOptions(optionsTestPAC, pacman.WithLogger(pacman.NewSlogLogger(logger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pac.FindProxy("http://www.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `msg="Proxy found" url=http://www.example.com/`) {
+		t.Fatalf("Expected messages to be logged, got %s", buf.String())
+	}
+}
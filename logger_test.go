@@ -0,0 +1,99 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+	"github.com/saucelabs/sypl"
+	"github.com/saucelabs/sypl/level"
+)
+
+// Logger recording messages.
+type recordingLogger struct {
+	sync.Mutex
+
+	messages []string
+}
+
+func (l *recordingLogger) record(lvl, msg string, args ...any) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.messages = append(l.messages, fmt.Sprintf("%s %s %v", lvl, msg, args))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record("debug", msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record("info", msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record("warn", msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record("error", msg, args...) }
+
+func (l *recordingLogger) String() string {
+	l.Lock()
+	defer l.Unlock()
+
+	return strings.Join(l.messages, "\n")
+}
+
+func TestNewWithOptions_WithLogger(t *testing.T) {
+	logger := &recordingLogger{}
+
+	pac, err := pacman.NewWithOptions(optionsTestPAC, pacman.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pac.FindProxy("http://www.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logger.String(), "debug Parser created") ||
+		!strings.Contains(logger.String(), "debug Proxy found") {
+		t.Fatalf("Expected messages to be logged, got %s", logger)
+	}
+}
+
+func TestNewSyplLogger(t *testing.T) {
+	pac, err := pacman.NewWithOptions(optionsTestPAC,
+		pacman.WithLogger(pacman.NewSyplLogger(sypl.NewDefault("test", level.None))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pac.FindProxy("http://www.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Should be race-free under `go test -race`.
+func TestNewWithOptions_parallel(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			pac, err := pacman.New("resources/data.pac")
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			if _, err := pac.FindProxy("http://abcdomain.com/"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// How long the default `myIpAddress` source caches the addresses.
+const defaultMyIPAddressCacheTTL = 5 * time.Second
+
+// Default targets of the route-based `myIpAddress` source. Public DNS servers,
+// no traffic is sent to them.
+var defaultRouteTargets = []string{"8.8.8.8", "2001:4860:4860::8888"}
+
+// IPAddressSource provides the addresses returned by `myIpAddress`, and
+// `myIpAddressEx`.
+type IPAddressSource interface {
+	// IPAddresses returns the candidate addresses. The first one is returned by
+	// `myIpAddress`, all of them by `myIpAddressEx`.
+	IPAddresses(ctx context.Context) ([]net.IP, error)
+}
+
+// StaticIPAddressSource always returns the same IP literals.
+type StaticIPAddressSource []string
+
+// IPAddresses is the `IPAddressSource` interface implementation.
+func (s StaticIPAddressSource) IPAddresses(_ context.Context) ([]net.IP, error) {
+	ips := []net.IP{}
+
+	for _, address := range s {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", address)
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
+
+// InterfaceIPAddressSource returns the addresses of the `Name` interface. If
+// `Name` is empty, returns the global unicast addresses of all up interfaces.
+type InterfaceIPAddressSource struct {
+	Name string
+}
+
+// IPAddresses is the `IPAddressSource` interface implementation.
+func (s *InterfaceIPAddressSource) IPAddresses(_ context.Context) ([]net.IP, error) {
+	var ifs []net.Interface
+
+	if s.Name != "" {
+		ifn, err := net.InterfaceByName(s.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		ifs = []net.Interface{*ifn}
+	} else {
+		allIfs, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+
+		ifs = allIfs
+	}
+
+	ips := []net.IP{}
+
+	for _, ifn := range ifs {
+		if ifn.Flags&net.FlagUp != net.FlagUp {
+			continue
+		}
+
+		addrs, err := ifn.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ip, ok := addr.(*net.IPNet)
+			if !ok || ip.IP.IsUnspecified() || ip.IP.IsLinkLocalUnicast() {
+				continue
+			}
+
+			// Loopback is only considered if explicitly asked for.
+			if s.Name == "" && !ip.IP.IsGlobalUnicast() {
+				continue
+			}
+
+			ips = append(ips, ip.IP)
+		}
+	}
+
+	return ips, nil
+}
+
+// RouteIPAddressSource returns the source addresses the kernel would use to
+// reach each of `Targets` (`host`, or `host:port`). It relies on connecting an
+// UDP socket, no traffic is sent. Unreachable targets are skipped.
+//
+// If `Fallback` is set, its addresses are appended to the candidates.
+type RouteIPAddressSource struct {
+	// CacheTTL, if set, caches the addresses for that long, sparing the route
+	// probes, and the fallback on every `myIpAddress` call. Zero disables
+	// caching.
+	CacheTTL time.Duration
+
+	Fallback IPAddressSource
+	Targets  []string
+
+	mu        sync.Mutex
+	cached    []net.IP
+	expiresAt time.Time
+}
+
+// Probes the routes, and the fallback.
+func (s *RouteIPAddressSource) lookup(ctx context.Context) ([]net.IP, error) {
+	ips := []net.IP{}
+
+	var dialer net.Dialer
+
+	for _, target := range s.Targets {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, "53")
+		}
+
+		conn, err := dialer.DialContext(ctx, "udp", target)
+		if err != nil {
+			continue
+		}
+
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsUnspecified() {
+			ips = appendUniqueIP(ips, addr.IP)
+		}
+
+		conn.Close()
+	}
+
+	if s.Fallback != nil {
+		fallbackIPs, err := s.Fallback.IPAddresses(ctx)
+		if err != nil && len(ips) == 0 {
+			return nil, err
+		}
+
+		for _, ip := range fallbackIPs {
+			ips = appendUniqueIP(ips, ip)
+		}
+	}
+
+	return ips, nil
+}
+
+// IPAddresses is the `IPAddressSource` interface implementation.
+func (s *RouteIPAddressSource) IPAddresses(ctx context.Context) ([]net.IP, error) {
+	if s.CacheTTL <= 0 {
+		return s.lookup(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached == nil || !time.Now().Before(s.expiresAt) {
+		ips, err := s.lookup(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		s.cached = ips
+		s.expiresAt = time.Now().Add(s.CacheTTL)
+	}
+
+	return append([]net.IP(nil), s.cached...), nil
+}
+
+func appendUniqueIP(ips []net.IP, ip net.IP) []net.IP {
+	for _, existing := range ips {
+		if existing.Equal(ip) {
+			return ips
+		}
+	}
+
+	return append(ips, ip)
+}
+
+// Default `myIpAddress` source. Route-based, falling back to the addresses of
+// all up interfaces.
+func newDefaultIPAddressSource() IPAddressSource {
+	return &RouteIPAddressSource{
+		CacheTTL: defaultMyIPAddressCacheTTL,
+		Fallback: &InterfaceIPAddressSource{},
+		Targets:  defaultRouteTargets,
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Returns the name of the loopback interface. Skips the test if there's none.
+func loopbackInterfaceName(t *testing.T) string {
+	t.Helper()
+
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	for _, ifn := range ifs {
+		if ifn.Flags&net.FlagLoopback == net.FlagLoopback && ifn.Flags&net.FlagUp == net.FlagUp {
+			return ifn.Name
+		}
+	}
+
+	t.Skip("no loopback interface")
+
+	return ""
+}
+
+func TestParser_WithMyIPAddress(t *testing.T) {
+	pac, err := pacman.NewWithOptions("resources/data.pac",
+		pacman.WithMyIPAddress("10.10.5.20", "172.17.0.1"),
+		pacman.WithResolver(pacman.StaticResolver{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pac.FindProxyForURL("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r != "PROXY 1.2.3.4:8080" {
+		t.Fatalf("Expected the myIpAddress rule to match, got %s", r)
+	}
+
+	if got := evalPAC(t, "myIpAddressEx()", pacman.WithMyIPAddress("10.10.5.20", "172.17.0.1")); got != "10.10.5.20;172.17.0.1" {
+		t.Fatalf("Expected all addresses, got %s", got)
+	}
+}
+
+func TestParser_WithMyIPInterface(t *testing.T) {
+	name := loopbackInterfaceName(t)
+
+	if got := evalPAC(t, "myIpAddressEx()", pacman.WithMyIPInterface(name)); !strings.Contains(got, "127.0.0.1") {
+		t.Fatalf("Expected loopback addresses, got %s", got)
+	}
+
+	if got := evalPAC(t, "myIpAddress()", pacman.WithMyIPInterface("pacman-does-not-exist0")); got != "null" {
+		t.Fatalf("Expected null for unknown interface, got %s", got)
+	}
+
+	if got := evalPAC(t, "myIpAddressEx()", pacman.WithMyIPInterface("pacman-does-not-exist0")); got != "" {
+		t.Fatalf("Expected empty string for unknown interface, got %s", got)
+	}
+}
+
+func TestParser_WithMyIPRoute(t *testing.T) {
+	if got := evalPAC(t, "myIpAddress()", pacman.WithMyIPRoute("127.0.0.1:53")); got != "127.0.0.1" {
+		t.Fatalf("Expected route source address 127.0.0.1, got %s", got)
+	}
+}
+
+func TestRouteIPAddressSource_fallback(t *testing.T) {
+	source := &pacman.RouteIPAddressSource{
+		Fallback: pacman.StaticIPAddressSource{"10.1.2.3", "127.0.0.1"},
+		Targets:  []string{"127.0.0.1", "invalid target"},
+	}
+
+	ips, err := source.IPAddresses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 2 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) || !ips[1].Equal(net.IPv4(10, 1, 2, 3)) {
+		t.Fatalf("Expected route address first, then unique fallback ones, got %v", ips)
+	}
+}
+
+// Source counting calls.
+type countingIPAddressSource struct {
+	calls int
+}
+
+func (s *countingIPAddressSource) IPAddresses(_ context.Context) ([]net.IP, error) {
+	s.calls++
+
+	return []net.IP{net.IPv4(10, 1, 2, 3)}, nil
+}
+
+func TestRouteIPAddressSource_CacheTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{name: "Should work - not cached", wantCalls: 3},
+		{name: "Should work - cached", ttl: time.Minute, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := &countingIPAddressSource{}
+
+			source := &pacman.RouteIPAddressSource{
+				CacheTTL: tt.ttl,
+				Fallback: fallback,
+				Targets:  []string{"127.0.0.1"},
+			}
+
+			for i := 0; i < 3; i++ {
+				ips, err := source.IPAddresses(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(ips) != 2 {
+					t.Fatalf("Expected route, and fallback addresses, got %v", ips)
+				}
+			}
+
+			if fallback.calls != tt.wantCalls {
+				t.Fatalf("Expected %d lookups, got %d", tt.wantCalls, fallback.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/saucelabs/pacman/internal/credential"
+)
+
+// Option allows to configure a `Parser`.
+type Option func(p *Parser)
+
+// WithPoolSize sets the maximum number of goja runtimes evaluating the PAC
+// concurrently. Default is `runtime.GOMAXPROCS(0)`. Values lower than `1` are
+// ignored.
+func WithPoolSize(size int) Option {
+	return func(p *Parser) {
+		if size > 0 {
+			p.poolSize = size
+		}
+	}
+}
+
+// WithProxiesURIs sets credentials for each/any proxy specified in the PAC
+// content, using standard URI format (`scheme://credential@host`). These
+// credentials will be automatically set when `FindProxy` is called.
+func WithProxiesURIs(proxiesURIs ...string) Option {
+	return func(p *Parser) {
+		p.proxiesURIs = proxiesURIs
+	}
+}
+
+// WithEvaluationTimeout sets the default deadline of a PAC evaluation, and of
+// the PAC top-level code run at load time. Scripts running longer - e.g. an
+// infinite loop, or a slow `dnsResolve` - are aborted, and a `*TimeoutError` is
+// returned. Default is no timeout.
+func WithEvaluationTimeout(timeout time.Duration) Option {
+	return func(p *Parser) {
+		p.evaluationTimeout = timeout
+	}
+}
+
+// WithResolver sets the resolver used by the DNS builtins (`dnsResolve`,
+// `dnsResolveEx`, `isResolvable`, `isInNet`, etc). Default is `NetResolver`.
+func WithResolver(resolver Resolver) Option {
+	return func(p *Parser) {
+		if resolver != nil {
+			p.resolver = resolver
+		}
+	}
+}
+
+// WithAddressFamily sets the preference of the addresses returned by the DNS
+// builtins. Default is `AnyFamily`.
+func WithAddressFamily(family AddressFamily) Option {
+	return func(p *Parser) {
+		p.addressFamily = family
+	}
+}
+
+// WithDNSCache enables an in-process cache of the DNS builtins answers, shared
+// by all PAC evaluations. Resolved hosts are cached for `ttl`, hosts not found
+// for `negativeTTL`. Zero disables the respective caching. Once `maxSize` is
+// reached, the least recently used entry is evicted. Zero means unbound.
+func WithDNSCache(ttl, negativeTTL time.Duration, maxSize int) Option {
+	return func(p *Parser) {
+		p.dnsCache = newDNSCache(ttl, negativeTTL, maxSize)
+	}
+}
+
+// WithClock sets the clock providing the current time seen by the PAC, and the
+// time-based builtins. Default is the system clock. A per-evaluation override
+// is possible with `ContextWithTime`.
+func WithClock(clock Clock) Option {
+	return func(p *Parser) {
+		if clock != nil {
+			p.clock = clock
+		}
+	}
+}
+
+// WithMyIPAddressSource sets the source of the addresses returned by
+// `myIpAddress`, and `myIpAddressEx`. Default is route-based (the source
+// address used to reach public DNS servers), falling back to the addresses of
+// all up interfaces - cached for 5 seconds.
+func WithMyIPAddressSource(source IPAddressSource) Option {
+	return func(p *Parser) {
+		if source != nil {
+			p.myIPAddressSource = source
+		}
+	}
+}
+
+// WithMyIPAddress pins the addresses returned by `myIpAddress` (the first one),
+// and `myIpAddressEx`.
+func WithMyIPAddress(addresses ...string) Option {
+	return WithMyIPAddressSource(StaticIPAddressSource(addresses))
+}
+
+// WithMyIPInterface picks the addresses returned by `myIpAddress`, and
+// `myIpAddressEx` from the `name` interface.
+func WithMyIPInterface(name string) Option {
+	return WithMyIPAddressSource(&InterfaceIPAddressSource{Name: name})
+}
+
+// WithMyIPRoute picks the addresses returned by `myIpAddress`, and
+// `myIpAddressEx` from the source address the kernel would use to reach each of
+// `targets` (`host`, or `host:port`). No traffic is sent.
+func WithMyIPRoute(targets ...string) Option {
+	return WithMyIPAddressSource(&RouteIPAddressSource{Targets: targets})
+}
+
+// WithResultCache enables an LRU cache of the PAC results, used by
+// `FindProxyForURL`, and `FindProxy`. Results are keyed by scheme, and host -
+// assuming the PAC doesn't route based on the URL path, see
+// `WithResultCacheFullURL` otherwise. Results are cached for `ttl`, zero means
+// no expiration. Once `maxSize` is reached, the least recently used entry is
+// evicted. Zero means unbound.
+//
+// Note: PACs using the time-based builtins (`weekdayRange`, `dateRange`,
+// `timeRange`), `Date`, or dynamic code (`eval`, `Function`, `globalThis`,
+// `this[...]`) bypass the cache. So do evaluations with a time override
+// (`ContextWithTime`). Detection is static, and best-effort: don't enable the
+// cache for PACs reaching the time otherwise.
+func WithResultCache(ttl time.Duration, maxSize int) Option {
+	return func(p *Parser) {
+		p.resultCache = newResultCache(ttl, maxSize, false)
+	}
+}
+
+// WithResultCacheFullURL is like `WithResultCache`, but results are keyed by
+// the full URL.
+func WithResultCacheFullURL(ttl time.Duration, maxSize int) Option {
+	return func(p *Parser) {
+		p.resultCache = newResultCache(ttl, maxSize, true)
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to load remote PACs. Default is
+// `http.DefaultClient`.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Parser) {
+		if client != nil {
+			p.httpClient = client
+		}
+	}
+}
+
+// WithRequestTimeout sets the timeout of each request loading remote PACs -
+// retries included, see `WithRetries`. Default is 3 seconds.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(p *Parser) {
+		if timeout > 0 {
+			p.requestTimeout = timeout
+		}
+	}
+}
+
+// WithRoundTripper sets the transport of the requests loading remote PACs, e.g.
+// an `*http.Transport` going through a bootstrap proxy. It replaces the
+// transport of the HTTP client, see `WithHTTPClient`.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(p *Parser) {
+		p.roundTripper = rt
+	}
+}
+
+// WithRootCAs sets the root CAs trusted when loading remote PACs, e.g. an
+// internal CA. Default is the system ones. Requires the transport to be an
+// `*http.Transport` - the default.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(p *Parser) {
+		p.rootCAs = pool
+	}
+}
+
+// WithClientCertificates sets the certificates presented when loading remote
+// PACs (mTLS). Requires the transport to be an `*http.Transport` - the default.
+func WithClientCertificates(certificates ...tls.Certificate) Option {
+	return func(p *Parser) {
+		p.clientCertificates = certificates
+	}
+}
+
+// WithHeader adds a header to the requests loading remote PACs. Credentials,
+// and conditional requests headers set by the loader have precedence.
+func WithHeader(name, value string) Option {
+	return func(p *Parser) {
+		if p.headers == nil {
+			p.headers = make(http.Header)
+		}
+
+		p.headers.Add(name, value)
+	}
+}
+
+// WithUserAgent sets the `User-Agent` of the requests loading remote PACs.
+// Default is the Go HTTP client one.
+func WithUserAgent(userAgent string) Option {
+	return func(p *Parser) {
+		p.userAgent = userAgent
+	}
+}
+
+// WithRetries retries loading remote PACs up to `retries` times on network
+// errors, and 5xx responses. The first retry waits `backoff`, doubling for
+// each subsequent one. Default is no retry. Backoff defaults to 500
+// milliseconds.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(p *Parser) {
+		if retries >= 0 {
+			p.retries = retries
+		}
+
+		if backoff > 0 {
+			p.retryBackoff = backoff
+		}
+	}
+}
+
+// WithCacheDir stores each successfully loaded remote PAC, and its validators
+// in `dir` - created if needed. The cached copy is revalidated with a
+// conditional request, and used if loading fails - see `Parser.Stale`.
+func WithCacheDir(dir string) Option {
+	return func(p *Parser) {
+		p.cacheDir = dir
+	}
+}
+
+// WithFallback sets the PAC content used if loading the remote PAC fails, and
+// there's no cached copy - see `WithCacheDir`, and `Parser.Stale`.
+func WithFallback(content string) Option {
+	return func(p *Parser) {
+		p.fallbackPAC = content
+	}
+}
+
+// WithFallbackDirect is like `WithFallback`, with a PAC always returning
+// `DIRECT`.
+func WithFallbackDirect() Option {
+	return WithFallback(directPAC)
+}
+
+// WithPACCredential sets the basic auth credential used to load remote PACs.
+// It has precedence over the one in the PAC URI, but not over `PACMAN_AUTH`.
+func WithPACCredential(username, password string) Option {
+	return func(p *Parser) {
+		p.pacCredential = &credential.BasicAuth{
+			Username: username,
+			Password: password,
+		}
+	}
+}
+
+// WithEnvVars enables, or disables the lookup of the `PACMAN_AUTH`, and
+// `PACMAN_PROXIES_AUTH` env vars. Default is enabled. When enabled, they have
+// precedence over `WithPACCredential`, and `WithProxiesURIs`.
+func WithEnvVars(enabled bool) Option {
+	return func(p *Parser) {
+		p.envVars = enabled
+	}
+}
+
+// WithStrict enables the strict mode:
+//   - The PAC must define `FindProxyForURL`, or `FindProxyForURLEx`, checked
+//     at creation time
+//   - `FindProxy` fails if the PAC returns an unknown proxy mode.
+func WithStrict() Option {
+	return func(p *Parser) {
+		p.strict = true
+	}
+}
+
+// WithLogger sets the logger. Default is a no-op one. See `NewSlogLogger`, and
+// `NewSyplLogger` adapters.
+func WithLogger(logger Logger) Option {
+	return func(p *Parser) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// WithProxyHealth sets the registry of bad proxies. `FindProxy` moves the bad
+// ones to the end of the list - or removes them, see `ProxyHealth.Skip`. A
+// `Dialer` using the parser reports failures, and successes to it.
+func WithProxyHealth(health *ProxyHealth) Option {
+	return func(p *Parser) {
+		p.proxyHealth = health
+	}
+}
+
+// WithTracing enables tracing: `FindProxyForURLTrace` records every call to the
+// builtins, with arguments, results, and durations. It adds a small overhead to
+// every builtin call, even when not tracing.
+func WithTracing() Option {
+	return func(p *Parser) {
+		p.tracing = true
+	}
+}
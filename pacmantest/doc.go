@@ -0,0 +1,37 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package pacmantest runs PAC regression tests: suites of URL, and expected
+// result pairs, with mocked `myIpAddress`, DNS answers, and clock. Suites are
+// JSON files:
+//
+//	{
+//	  "myIpAddress": ["10.0.0.5"],
+//	  "dns": {"intranet.example.com": ["10.1.2.3"]},
+//	  "time": "2022-01-03T10:00:00Z",
+//	  "tests": [
+//	    {"url": "http://intranet.example.com/", "want": "DIRECT"},
+//	    {
+//	      "name": "Off the VPN",
+//	      "url": "http://www.example.com/",
+//	      "myIpAddress": ["192.168.1.2"],
+//	      "want": "PROXY proxy.example.com:8080; DIRECT"
+//	    }
+//	  ]
+//	}
+//
+// Mocks set at the suite level apply to all tests, unless overridden. Without
+// `dns`, the system resolver is used, with it, unlisted hosts aren't resolved.
+// Results are compared ignoring spacing.
+//
+// From Go tests:
+//
+//	func TestPAC(t *testing.T) {
+//		pac, err := pacman.New("proxy.pac")
+//		...
+//		pacmantest.TestFile(t, pac, "testdata/proxy.json")
+//	}
+//
+// From the CLI: `pacman test proxy.pac testdata/proxy.json`.
+package pacmantest
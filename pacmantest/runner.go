@@ -0,0 +1,213 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/saucelabs/pacman"
+)
+
+// `myIpAddress` if not mocked.
+const defaultMyIPAddress = "127.0.0.1"
+
+//////
+// Helpers.
+//////
+
+// Splits the PAC `result` into its entries, normalizing spacing.
+func entries(result string) []string {
+	e := []string{}
+
+	for _, entry := range strings.Split(result, ";") {
+		if entry = strings.Join(strings.Fields(entry), " "); entry != "" {
+			e = append(e, entry)
+		}
+	}
+
+	return e
+}
+
+// Diffs `want`, and `got` entries, line by line: `-` for missing, `+` for
+// unexpected, based on their longest common subsequence.
+func diff(want, got []string) string {
+	// lcs[i][j] is the LCS length of want[i:], and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			switch {
+			case want[i] == got[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	i, j := 0, 0
+
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(&b, "  %s\n", want[i])
+			i++
+			j++
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&b, "- %s\n", want[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "+ %s\n", got[j])
+			j++
+		}
+	}
+
+	return b.String()
+}
+
+// Describes `c` by name, and URL.
+func describe(c Case) string {
+	if c.Name == "" {
+		return c.URL
+	}
+
+	return fmt.Sprintf("%s (%s)", c.Name, c.URL)
+}
+
+// Returns the parser options implementing `mocks`.
+func mockOptions(mocks Mocks) []pacman.Option {
+	// Hermetic by default: no DNS query, and no network probe.
+	opts := []pacman.Option{
+		pacman.WithResolver(pacman.StaticResolver{}),
+		pacman.WithMyIPAddress(defaultMyIPAddress),
+	}
+
+	if mocks.DNS != nil {
+		opts = append(opts, pacman.WithResolver(pacman.StaticResolver(mocks.DNS)))
+	}
+
+	if mocks.MyIPAddress != nil {
+		opts = append(opts, pacman.WithMyIPAddress(mocks.MyIPAddress...))
+	}
+
+	return opts
+}
+
+//////
+// Exported.
+//////
+
+// Result of a test.
+type Result struct {
+	// Case run.
+	Case Case
+
+	// Err is the evaluation error, if any.
+	Err error
+
+	// Got is the PAC result.
+	Got string
+}
+
+// Passed returns true if the result is the expected one.
+func (r Result) Passed() bool {
+	return r.Err == nil && strings.Join(entries(r.Got), "; ") == strings.Join(entries(r.Case.Want), "; ")
+}
+
+// Diff returns the difference between the expected, and the actual result, one
+// entry per line: `-` for missing, `+` for unexpected. Empty if passed.
+func (r Result) Diff() string {
+	if r.Passed() {
+		return ""
+	}
+
+	if r.Err != nil {
+		return fmt.Sprintf("- %s\n+ error: %s\n", strings.Join(entries(r.Case.Want), "; "), r.Err)
+	}
+
+	return diff(entries(r.Case.Want), entries(r.Got))
+}
+
+// Report of a suite run.
+type Report struct {
+	// Results, in order.
+	Results []Result
+}
+
+// Failed returns the results which didn't pass.
+func (r *Report) Failed() []Result {
+	failed := []Result{}
+
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// String returns a human-readable report: the diff of each failure, and a
+// summary.
+func (r *Report) String() string {
+	var b strings.Builder
+
+	failed := r.Failed()
+
+	for _, result := range failed {
+		fmt.Fprintf(&b, "FAIL %s\n", describe(result.Case))
+
+		for _, line := range strings.Split(strings.TrimSuffix(result.Diff(), "\n"), "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "%d passed, %d failed\n", len(r.Results)-len(failed), len(failed))
+
+	return b.String()
+}
+
+// Run runs the suite against `pac`. Each test is evaluated by a clone of `pac`
+// (see `Parser.Clone`) - keeping its configuration, e.g. proxies credentials,
+// and strict mode - with the mocks, then `opts` applied. Unmocked hosts don't
+// resolve, and `myIpAddress` defaults to 127.0.0.1, so the network is never
+// used.
+func (s *Suite) Run(ctx context.Context, pac *pacman.Parser, opts ...pacman.Option) (*Report, error) {
+	report := &Report{}
+
+	for _, c := range s.Tests {
+		mocks := s.Mocks.merge(c.Mocks)
+
+		parser, err := pac.Clone(append(mockOptions(mocks), opts...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		evalCtx := ctx
+
+		if t, ok, err := mocks.time(); err != nil {
+			return nil, fmt.Errorf("test %s: %w", c, err)
+		} else if ok {
+			evalCtx = pacman.ContextWithTime(ctx, t)
+		}
+
+		got, err := parser.FindProxyForURLContext(evalCtx, c.URL)
+
+		report.Results = append(report.Results, Result{Case: c, Err: err, Got: got})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+	"github.com/saucelabs/pacman/pacmantest"
+)
+
+func TestSuite_Run(t *testing.T) {
+	pac, err := pacman.New("testdata/proxy.pac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite, err := pacmantest.Load("testdata/proxy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := suite.Run(context.Background(), pac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Fatalf("Expected no failure, got:\n%s", report)
+	}
+
+	// Breaks the expectations.
+	suite.Tests[1].Want = "PROXY other.example.com:8080;DIRECT; PROXY office.example.com:8080"
+	suite.Tests[3].Want = "DIRECT"
+	suite.Tests[3].Time = "2022-01-05T10:00:00Z"
+
+	report, err = suite.Run(context.Background(), pac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := report.Failed()
+	if len(failed) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failed))
+	}
+
+	wantDiff := "- PROXY other.example.com:8080\n- DIRECT\n  PROXY office.example.com:8080\n+ DIRECT\n"
+	if got := failed[0].Diff(); got != wantDiff {
+		t.Fatalf("Expected diff:\n%s\ngot:\n%s", wantDiff, got)
+	}
+
+	wantReport := `FAIL http://www.example.com/
+    - PROXY other.example.com:8080
+    - DIRECT
+      PROXY office.example.com:8080
+    + DIRECT
+
+FAIL Weekend (http://www.example.com/)
+    + PROXY office.example.com:8080
+      DIRECT
+
+2 passed, 2 failed
+`
+	if got := report.String(); got != wantReport {
+		t.Fatalf("Expected report:\n%s\ngot:\n%s", wantReport, got)
+	}
+}
+
+func TestResult_Diff_error(t *testing.T) {
+	pac, err := pacman.New(`function FindProxyForURL(url, host) { throw "boom"; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite, err := pacmantest.Parse([]byte(`{"tests": [{"url": "http://www.example.com/", "want": "DIRECT"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := suite.Run(context.Background(), pac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Results[0].Passed() || report.Results[0].Err == nil {
+		t.Fatal("Expected failure with error")
+	}
+}
+
+func TestSuite_Run_parserConfiguration(t *testing.T) {
+	now := time.Date(2022, time.January, 8, 10, 0, 0, 0, time.UTC)
+
+	pac, err := pacman.NewWithOptions(`
+function FindProxyForURL(url, host) {
+  if (dateRange(2022, "GMT")) return "DIRECT";
+
+  return "PROXY 1.2.3.4:8080";
+}`, pacman.WithClock(pacman.ClockFunc(func() time.Time { return now })))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite, err := pacmantest.Parse([]byte(`{"tests": [
+  {"url": "http://www.example.com/", "want": "DIRECT"},
+  {"url": "http://www.example.com/", "time": "2023-01-05T10:00:00Z", "want": "PROXY 1.2.3.4:8080"}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The parser clock is kept, unless mocked.
+	report, err := suite.Run(context.Background(), pac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Fatalf("Expected no failure, got:\n%s", report)
+	}
+}
+
+func TestSuite_Run_hermetic(t *testing.T) {
+	pac, err := pacman.New(`
+function FindProxyForURL(url, host) {
+  if (isResolvable(host)) return "PROXY " + dnsResolve(host) + ":8080";
+
+  return "PROXY " + myIpAddress() + ":3128";
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite, err := pacmantest.Parse([]byte(`{"tests": [
+  {"url": "http://localhost/", "want": "PROXY 127.0.0.1:3128"},
+  {"url": "http://intranet/", "dns": {"intranet": ["10.1.2.3"]}, "want": "PROXY 10.1.2.3:8080"},
+  {"url": "http://localhost/", "myIpAddress": ["10.0.0.5"], "want": "PROXY 10.0.0.5:3128"}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := suite.Run(context.Background(), pac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Fatalf("Expected no failure, got:\n%s", report)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Mocks of the environment seen by the PAC.
+type Mocks struct {
+	// DNS answers, by host. Unlisted hosts aren't resolved.
+	DNS map[string][]string `json:"dns,omitempty"`
+
+	// MyIPAddress is returned by `myIpAddress` (the first one), and
+	// `myIpAddressEx`. Default is 127.0.0.1.
+	MyIPAddress []string `json:"myIpAddress,omitempty"`
+
+	// Time seen by the PAC, RFC 3339 formatted. Default is the current time.
+	Time string `json:"time,omitempty"`
+}
+
+// Returns `m` overridden by the non-empty fields of `override`.
+func (m Mocks) merge(override Mocks) Mocks {
+	if override.DNS != nil {
+		m.DNS = override.DNS
+	}
+
+	if override.MyIPAddress != nil {
+		m.MyIPAddress = override.MyIPAddress
+	}
+
+	if override.Time != "" {
+		m.Time = override.Time
+	}
+
+	return m
+}
+
+// Parses the mocked time, if any.
+func (m Mocks) time() (time.Time, bool, error) {
+	if m.Time == "" {
+		return time.Time{}, false, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, m.Time)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q, expected RFC 3339", m.Time)
+	}
+
+	return t, true, nil
+}
+
+// Case is a single test: the PAC result expected for a URL.
+type Case struct {
+	Mocks
+
+	// Name, optional. Defaults to the URL.
+	Name string `json:"name,omitempty"`
+
+	// URL evaluated.
+	URL string `json:"url"`
+
+	// Want is the expected result, e.g.: `PROXY 1.2.3.4:8080; DIRECT`.
+	Want string `json:"want"`
+}
+
+// String returns the name of the test.
+func (c Case) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return c.URL
+}
+
+// Suite is a set of tests, sharing mocks.
+type Suite struct {
+	Mocks
+
+	// Tests, in order.
+	Tests []Case `json:"tests"`
+}
+
+// Validates the suite.
+func (s *Suite) validate() error {
+	if _, _, err := s.time(); err != nil {
+		return err
+	}
+
+	for i, c := range s.Tests {
+		if c.URL == "" {
+			return fmt.Errorf("test #%d: missing url", i+1)
+		}
+
+		if c.Want == "" {
+			return fmt.Errorf("test %s: missing want", c)
+		}
+
+		if _, _, err := c.time(); err != nil {
+			return fmt.Errorf("test %s: %w", c, err)
+		}
+	}
+
+	return nil
+}
+
+// Parse parses a JSON suite. Unknown fields are rejected, to catch typos.
+func Parse(data []byte) (*Suite, error) {
+	var s Suite
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&s); err != nil {
+		return nil, err
+	}
+
+	if len(s.Tests) == 0 {
+		return nil, errors.New("no tests")
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Load loads a JSON suite from `filename`.
+func Load(filename string) (*Suite, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest_test
+
+import (
+	"testing"
+
+	"github.com/saucelabs/pacman/pacmantest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "Should work",
+			data: `{"tests": [{"url": "http://www.example.com/", "want": "DIRECT"}]}`,
+		},
+		{
+			name: "Should work - mocks",
+			data: `{
+				"dns": {"a.example.com": ["10.1.2.3"]},
+				"myIpAddress": ["10.0.0.5"],
+				"time": "2022-01-03T10:00:00Z",
+				"tests": [{"url": "http://www.example.com/", "want": "DIRECT", "time": "2022-01-03T10:00:00+01:00"}]
+			}`,
+		},
+		{
+			name:    "Should fail - no tests",
+			data:    `{"tests": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - unknown field",
+			data:    `{"tests": [{"url": "http://www.example.com/", "wnat": "DIRECT"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - missing want",
+			data:    `{"tests": [{"url": "http://www.example.com/"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - invalid time",
+			data:    `{"time": "2022-01-03", "tests": [{"url": "http://www.example.com/", "want": "DIRECT"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - invalid JSON",
+			data:    `{"tests": [`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pacmantest.Parse([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	suite, err := pacmantest.Load("testdata/proxy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(suite.Tests) != 4 {
+		t.Fatalf("Expected 4 tests, got %d", len(suite.Tests))
+	}
+
+	if suite.Tests[2].String() != "Off the VPN" || suite.Tests[0].String() != "http://intranet.example.com/" {
+		t.Fatalf("Expected tests names, got %s, and %s", suite.Tests[2], suite.Tests[0])
+	}
+
+	if _, err := pacmantest.Load("testdata/missing.json"); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
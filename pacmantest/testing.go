@@ -0,0 +1,39 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Test runs `suite` against `pac`, reporting each failure, with its diff, as a
+// test error.
+func Test(t testing.TB, pac *pacman.Parser, suite *Suite, opts ...pacman.Option) {
+	t.Helper()
+
+	report, err := suite.Run(context.Background(), pac, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range report.Failed() {
+		t.Errorf("%s:\n%s", describe(result.Case), result.Diff())
+	}
+}
+
+// TestFile loads the suite from `filename`, and runs it with `Test`.
+func TestFile(t testing.TB, pac *pacman.Parser, filename string, opts ...pacman.Option) {
+	t.Helper()
+
+	suite, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Test(t, pac, suite, opts...)
+}
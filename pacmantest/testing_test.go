@@ -0,0 +1,54 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacmantest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+	"github.com/saucelabs/pacman/pacmantest"
+)
+
+// Records errors instead of failing.
+type recordingTB struct {
+	testing.TB
+
+	errors []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTestFile(t *testing.T) {
+	pac, err := pacman.New("testdata/proxy.pac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pacmantest.TestFile(t, pac, "testdata/proxy.json")
+
+	// A regression: the office proxy is gone.
+	regressed, err := pacman.New(strings.ReplaceAll(pac.Content(), "office", "proxy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tb := &recordingTB{TB: t}
+
+	pacmantest.TestFile(tb, regressed, "testdata/proxy.json")
+
+	if len(tb.errors) != 1 {
+		t.Fatalf("Expected 1 error, got %d: %v", len(tb.errors), tb.errors)
+	}
+
+	if want := "- PROXY office.example.com:8080\n+ PROXY proxy.example.com:8080\n"; !strings.Contains(tb.errors[0], want) {
+		t.Fatalf("Expected error to contain the diff %q, got %s", want, tb.errors[0])
+	}
+}
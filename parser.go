@@ -6,14 +6,19 @@ package pacman
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"runtime"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/dop251/goja"
@@ -21,15 +26,12 @@ import (
 	"github.com/saucelabs/pacman/internal/credential"
 	"github.com/saucelabs/pacman/internal/utils"
 	"github.com/saucelabs/pacman/internal/validation"
-	"github.com/saucelabs/sypl"
-	"github.com/saucelabs/sypl/fields"
-	"github.com/saucelabs/sypl/level"
-	"github.com/saucelabs/sypl/options"
 )
 
-const defaultRequestTimeout = 3
-
-var l *sypl.Sypl
+const (
+	dataURIScheme         = "data:"
+	defaultRequestTimeout = 3 * time.Second
+)
 
 // Localhost regex. Based on Golang `net.Listen` and `net.LookupStaticHost` tests.
 var localhostRegex = regexp.MustCompile(`(?mi)0\.0\.0\.0|127\.0\.0\.1|localhost`)
@@ -40,6 +42,15 @@ type ProxiesCredentials map[string]*credential.BasicAuth
 // Helpers.
 //////
 
+// Returns the value of the env var, unless env vars lookup is disabled.
+func (p *Parser) getenv(envVar string) string {
+	if !p.envVars {
+		return ""
+	}
+
+	return os.Getenv(envVar)
+}
+
 // Checks if the given URI is "localhost".
 func IsLocalhost(uri *url.URL) bool {
 	return localhostRegex.MatchString(uri.Host)
@@ -51,8 +62,8 @@ func EqualLocalhost(uri1, uri2 *url.URL) bool {
 }
 
 // Loads, validate credential from env var, and set URI's user.
-func setCredentialFromEnvVar(envVar string, uri *url.URL, req *http.Request) error {
-	credentialFromEnvVar := os.Getenv(envVar)
+func (p *Parser) setCredentialFromEnvVar(envVar string, uri *url.URL, req *http.Request) error {
+	credentialFromEnvVar := p.getenv(envVar)
 
 	if credentialFromEnvVar != "" {
 		if err := validation.Get().Var(credentialFromEnvVar, "basicAuth"); err != nil {
@@ -71,9 +82,38 @@ func setCredentialFromEnvVar(envVar string, uri *url.URL, req *http.Request) err
 	return nil
 }
 
-func registerBuiltinNatives(vm *goja.Runtime) error {
+// Decodes the content of a `data:` URI: `data:[<media type>][;base64],<data>`.
+// See RFC 2397.
+func decodeDataURI(uri string) (string, error) {
+	if !isDataSource(uri) {
+		return "", customerror.NewInvalidError("data URI, missing `data:` scheme")
+	}
+
+	metadata, data, found := strings.Cut(uri[len(dataURIScheme):], ",")
+	if !found {
+		return "", customerror.NewInvalidError("data URI, missing `,`")
+	}
+
+	if strings.HasSuffix(strings.ToLower(metadata), ";base64") {
+		buf, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", customerror.NewInvalidError("data URI", customerror.WithError(err))
+		}
+
+		return string(buf), nil
+	}
+
+	content, err := url.PathUnescape(data)
+	if err != nil {
+		return "", customerror.NewInvalidError("data URI", customerror.WithError(err))
+	}
+
+	return content, nil
+}
+
+func registerBuiltinNatives(e *engine) error {
 	for name, function := range builtinNatives {
-		if err := vm.Set(name, function(vm)); err != nil {
+		if err := e.vm.Set(name, function(e)); err != nil {
 			return err
 		}
 	}
@@ -82,7 +122,7 @@ func registerBuiltinNatives(vm *goja.Runtime) error {
 }
 
 func registerBuiltinJS(vm *goja.Runtime) error {
-	_, err := vm.RunString(builtinJS)
+	_, err := vm.RunProgram(builtinJSProgram)
 
 	return err
 }
@@ -130,36 +170,57 @@ func processProxiesCredentials(proxiesURIs ...string) (ProxiesCredentials, error
 	return proxiesCredentials, nil
 }
 
-// Initializes Goja, parse PAC content, and process proxies credentials.
-func initialize(source, content string, proxiesURIs ...string) (*Parser, error) {
-	if err := validation.Get().Var(content, "pacTextOrURI"); err != nil {
-		return nil, customerror.NewInvalidError("params", customerror.WithError(err))
+// Compiles PAC content, initializes the engine pool, and process proxies
+// credentials.
+func (p *Parser) initialize(source, content string) error {
+	// Loaded by `New`, content is checked as it always was.
+	if p.heuristic {
+		if err := validation.Get().Var(content, "pacTextOrURI"); err != nil {
+			return customerror.NewInvalidError("params", customerror.WithError(err))
+		}
 	}
 
-	vm := goja.New()
+	if strings.TrimSpace(content) == "" {
+		return customerror.NewRequiredError("PAC content")
+	}
 
-	if err := registerBuiltinNatives(vm); err != nil {
-		return nil, err
+	program, err := goja.Compile(source, content, false)
+	if err != nil {
+		return err
 	}
 
-	if err := registerBuiltinJS(vm); err != nil {
-		return nil, err
+	// Runs the PAC once, surfacing errors early, within the evaluation
+	// timeout - if set. The engine is kept in the pool.
+	ctx := context.Background()
+
+	if p.evaluationTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.evaluationTimeout)
+		defer cancel()
 	}
 
-	if _, err := vm.RunString(content); err != nil {
-		return nil, err
+	e, err := newEngine(ctx, p, program)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &TimeoutError{URL: source, Err: ctx.Err()}
+		}
+
+		return err
+	}
+
+	if p.strict && e.findProxyForURL == nil {
+		return customerror.NewMissingError("PAC `FindProxyForURL`, or `FindProxyForURLEx` function")
 	}
 
-	l.PrintlnWithOptions(&options.Options{
-		Fields: fields.Fields{
-			"content": "\n" + content,
-		},
-	}, level.Trace, "PAC")
+	p.logger.Debug("PAC", "content", "\n"+content)
 
 	// Associates a proxy - specified in PAC, with its credential - if any.
 	var proxiesCredentials ProxiesCredentials
 
-	proxiesURIsEnvVar := os.Getenv("PACMAN_PROXIES_AUTH")
+	proxiesURIs := p.proxiesURIs
+
+	proxiesURIsEnvVar := p.getenv("PACMAN_PROXIES_AUTH")
 	if proxiesURIsEnvVar != "" {
 		proxiesURIsFromEnvVar := strings.Split(proxiesURIsEnvVar, ",")
 
@@ -171,124 +232,125 @@ func initialize(source, content string, proxiesURIs ...string) (*Parser, error)
 	if proxiesURIs != nil {
 		pC, err := processProxiesCredentials(proxiesURIs...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		proxiesCredentials = pC
 	}
 
-	p := &Parser{
-		content:            content,
-		source:             source,
-		vm:                 vm,
-		proxiesCredentials: proxiesCredentials,
-	}
+	p.content = content
+	p.source = source
+	p.timeDependent = usesTimeBuiltins(content)
+	p.proxiesCredentials = proxiesCredentials
+	p.pool = newEnginePool(p, program, p.poolSize)
+	p.pool.idle <- e
 
-	l.PrintlnWithOptions(&options.Options{
-		Fields: fields.Fields{
-			"source":     source,
-			"credential": proxiesCredentials,
-		},
-	}, level.Debug, "Parser created")
+	p.logger.Debug("Parser created",
+		"source", source,
+		"credential", proxiesCredentials,
+		"poolSize", p.poolSize,
+	)
 
-	return p, nil
+	return nil
 }
 
-// Centralized PAC content reading. Optionally, receives a list of proxies URIs
-// which will be used to map each proxy to its credential.
-func fromReader(source string, r io.ReadCloser, proxiesURIs ...string) (*Parser, error) {
+// Centralized PAC content reading.
+func (p *Parser) fromReader(source string, r io.ReadCloser) error {
 	defer r.Close()
 
 	buf, err := io.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return initialize(source, string(buf), proxiesURIs...)
+	return p.initialize(source, string(buf))
 }
 
-// File loader. Optionally, receives a list of proxies URIs which will be
-// used to map each proxy to its credential.
+// File loader.
 //
 // NOTE:
 // - Absolute, and relative paths are supported.
 // - `file://` scheme is supported. IT SHOULD BE AN ABSOLUTE PATH:
 //   - SEE: https://datatracker.ietf.org/doc/html/rfc1738#section-3.10
 //   - SEE: https://datatracker.ietf.org/doc/html/draft-ietf-appsawg-file-scheme-03#section-2
-func fromFile(filename string, proxiesURIs ...string) (*Parser, error) {
+func (p *Parser) fromFile(filename string) error {
 	resolvedFilename, err := utils.FilenameResolver(filename)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	f, err := os.Open(resolvedFilename)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return fromReader(filename, f, proxiesURIs...)
+	return p.fromReader(filename, f)
 }
 
-// Remote loader (http/https). Optionally, receives a list of proxies URIs which
-// will be used to map each proxy to its credential.
-func fromURL(uri string, proxiesURIs ...string) (*Parser, error) {
-	u, err := url.ParseRequestURI(uri)
-	if err != nil {
-		return nil, err
+// Loads the PAC at `uri` - conditionally if `v` is set, storing it in the
+// cache dir, if any.
+func (p *Parser) loadURL(ctx context.Context, uri string, v validators) (newV validators, notModified bool, err error) {
+	content, newV, notModified, err := p.fetchURL(ctx, uri, v)
+	if err != nil || notModified {
+		return newV, notModified, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
+	if err := p.initialize(uri, content); err != nil {
+		return v, false, err
 	}
 
-	if u.User != nil {
-		if err := validation.Get().Var(u.User.String(), "basicAuth"); err != nil {
-			return nil, customerror.NewInvalidError("PAC URI credential", customerror.WithError(err))
-		}
+	p.writeCachedPAC(uri, content, newV)
+
+	return newV, false, nil
+}
 
-		password, _ := u.User.Password()
+// Remote loader (http/https). The cached copy, if any, is revalidated. If
+// loading fails, the last-known-good PAC is used, see `fallback`.
+func (p *Parser) fromURL(ctx context.Context, uri string) (validators, error) {
+	v := validators{}
 
-		req.SetBasicAuth(u.User.Username(), password)
+	cached := p.readCachedPAC(uri)
+	if cached != nil {
+		v = cached.validators()
 	}
 
-	if err := setCredentialFromEnvVar("PACMAN_AUTH", u, req); err != nil {
-		return nil, err
+	newV, notModified, err := p.loadURL(ctx, uri, v)
+	if err == nil && notModified {
+		if err = p.initialize(uri, cached.Content); err == nil {
+			p.writeCachedPAC(uri, cached.Content, v)
+		}
 	}
 
-	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return validators{}, p.fallback(uri, cached, err)
 	}
 
-	statusCode := resp.StatusCode
-
-	// Checks if request succeeded.
-	if statusCode != http.StatusOK {
-		// Check if status code is valid, otherwise fallback to `500`.
-		if statusCode < http.StatusContinue ||
-			statusCode > http.StatusNetworkAuthenticationRequired {
-			statusCode = http.StatusInternalServerError
-		}
+	return newV, nil
+}
 
-		errMsg := fmt.Sprintf("failed to retrieve PAC content from %s", u.Redacted())
+// `data:` URI loader.
+func (p *Parser) fromDataURI(uri string) error {
+	content, err := decodeDataURI(uri)
+	if err != nil {
+		return err
+	}
 
-		resp.Body.Close()
+	return p.initialize("data", content)
+}
 
-		return nil, customerror.New(errMsg, customerror.WithStatusCode(statusCode), customerror.WithError(err))
+// `fs.FS` loader.
+func (p *Parser) fromFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
 	}
 
-	// Should only read body if request succeeded.
-	return fromReader(uri, resp.Body, proxiesURIs...)
+	return p.fromReader(name, f)
 }
 
-// Direct text loader. Optionally, receives a list of proxies URIs which will be
-// used to map each proxy to its credential.
-func fromText(text string, proxiesURIs ...string) (*Parser, error) {
-	return fromReader("text", io.NopCloser(strings.NewReader(text)), proxiesURIs...)
+// Direct text loader.
+func (p *Parser) fromText(text string) error {
+	return p.fromReader("text", io.NopCloser(strings.NewReader(text)))
 }
 
 //////
@@ -297,14 +359,67 @@ func fromText(text string, proxiesURIs ...string) (*Parser, error) {
 
 // Parser definition.
 type Parser struct {
-	sync.Mutex
-
+	addressFamily      AddressFamily
+	cacheDir           string
+	clientCertificates []tls.Certificate
+	clock              Clock
 	content            string
+	dnsCache           *dnsCache
+	envVars            bool
+	evaluationTimeout  time.Duration
+	fallbackPAC        string
+	headers            http.Header
+	heuristic          bool
+	httpClient         *http.Client
+	logger             Logger
+	myIPAddressSource  IPAddressSource
+	opts               []Option
+	pacCredential      *credential.BasicAuth
+	pool               *enginePool
+	poolSize           int
 	proxiesCredentials ProxiesCredentials
+	proxiesURIs        []string
+	proxyHealth        *ProxyHealth
+	resolver           Resolver
+	resultCache        *resultCache
+	requestTimeout     time.Duration
+	retries            int
+	retryBackoff       time.Duration
+	rootCAs            *x509.CertPool
+	roundTripper       http.RoundTripper
 	source             string
-	vm                 *goja.Runtime
+	staleness          *Staleness
+	strict             bool
+	timeDependent      bool
+	tracing            bool
+	userAgent          string
 }
 
+// Clone creates a Parser from the same PAC - not loaded again - with the
+// options `p` was created with, then `opts`. Caches aren't shared.
+func (p *Parser) Clone(opts ...Option) (*Parser, error) {
+	c := newParser(append(append([]Option{}, p.opts...), opts...)...)
+
+	if err := c.initialize(p.source, p.content); err != nil {
+		return nil, err
+	}
+
+	c.staleness = p.staleness
+
+	return c, nil
+}
+
+// Lock is a no-op, kept for compatibility. The Parser no longer embeds a
+// mutex: evaluations are safe for concurrent use.
+//
+// Deprecated: Not needed, to be removed in the next major version.
+func (p *Parser) Lock() {}
+
+// Unlock is a no-op, kept for compatibility. See `Lock`.
+//
+// Deprecated: Not needed, to be removed in the next major version.
+func (p *Parser) Unlock() {}
+
 // Source of the PAC content.
 func (p *Parser) Source() string {
 	return p.source
@@ -315,22 +430,111 @@ func (p *Parser) Content() string {
 	return p.content
 }
 
+// ProxyHealth returns the registry of bad proxies set by `WithProxyHealth`, if
+// any.
+func (p *Parser) ProxyHealth() *ProxyHealth {
+	return p.proxyHealth
+}
+
+// Proxies returns the proxies statically found in the PAC content, with their
+// credentials set - if any. Proxies built at runtime, e.g. by concatenation,
+// aren't found.
+func (p *Parser) Proxies() []Proxy {
+	proxies := []Proxy{}
+	seen := map[string]bool{}
+
+	for _, literal := range proxyLiterals(p.content) {
+		parsedProxies, err := ParseProxy(literal)
+		if err != nil {
+			continue
+		}
+
+		for _, proxy := range parsedProxies {
+			key := healthKey(proxy)
+			if key == "" || seen[key] {
+				continue
+			}
+
+			seen[key] = true
+
+			p.setProxyCredential(proxy)
+
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
 // FindProxyForURL for the given `url`, returning as string, example:
 // "PROXY 4.5.6.7:8080; PROXY 7.8.9.10:8080; DIRECT".
 func (p *Parser) FindProxyForURL(uri string) (string, error) {
+	return p.FindProxyForURLContext(context.Background(), uri)
+}
+
+// FindProxyForURLContext is like `FindProxyForURL` but the evaluation is
+// aborted when `ctx` is done, or the evaluation timeout (if set) expires. In
+// that case, a `*TimeoutError` is returned.
+func (p *Parser) FindProxyForURLContext(ctx context.Context, uri string) (string, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return "", err
 	}
 
-	f := fmt.Sprintf("FindProxyForURL('%s', '%s')", uri, u.Hostname())
+	if entry, ok := p.cachedResult(ctx, u); ok {
+		return entry.result, nil
+	}
+
+	result, err := p.evaluate(ctx, uri, u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	p.cacheResult(ctx, u, &resultCacheEntry{result: result})
+
+	return result, nil
+}
+
+// Evaluates the PAC for `uri`. Builtin calls are recorded into `trace`, if any.
+func (p *Parser) evaluate(ctx context.Context, uri string, u *url.URL, trace *Trace) (string, error) {
+	if p.evaluationTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.evaluationTimeout)
+		defer cancel()
+	}
+
+	// Go routine safe, each engine is used by one caller at a time.
+	e, err := p.pool.get(ctx)
+	if err != nil {
+		// Engine creation errors, e.g. the PAC top-level code throwing, aren't
+		// timeouts.
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return "", &TimeoutError{URL: uri, Err: ctx.Err()}
+		}
+
+		return "", err
+	}
+
+	if e.findProxyForURL == nil {
+		p.pool.put(e)
+
+		return "", customerror.NewFailedToError("call `FindProxyForURL`. Is that defined?")
+	}
 
-	// Go routine safe.
-	p.Lock()
+	e.trace = trace
 
-	r, err := p.vm.RunString(f)
+	r, err := e.run(ctx, func(vm *goja.Runtime) (goja.Value, error) {
+		return e.findProxyForURL(goja.Undefined(), vm.ToValue(uri), vm.ToValue(u.Hostname()))
+	})
 
-	p.Unlock()
+	p.pool.put(e)
+
+	// Natives (e.g. `dnsResolve`) may have failed because of the context,
+	// so the result isn't trustworthy.
+	if ctx.Err() != nil {
+		return "", &TimeoutError{URL: uri, Err: ctx.Err()}
+	}
 
 	if err != nil {
 		return "", customerror.NewFailedToError(
@@ -342,17 +546,104 @@ func (p *Parser) FindProxyForURL(uri string) (string, error) {
 	return r.String(), nil
 }
 
+// ParseProxy is like the `ParseProxy` function, adding the proxies credentials
+// (`proxiesURIs`) - if any, and checking the modes in strict mode. Use it to
+// parse a `FindProxyForURL` result without evaluating the PAC again.
+func (p *Parser) ParseProxy(result string) ([]Proxy, error) {
+	return p.parseProxies("", result)
+}
+
 // FindProxy for the given `url`, returning a list of `Proxies`.
 //
 // Note: If the returned proxies requires credentials, and it was set when the
 // Parser was created (`proxiesURIs`), it will be automatically added to the
 // `Proxy`.
 func (p *Parser) FindProxy(uri string) ([]Proxy, error) {
-	proxiesAsString, err := p.FindProxyForURL(uri)
+	return p.FindProxyContext(context.Background(), uri)
+}
+
+// FindProxyContext is like `FindProxy` but the evaluation is aborted when `ctx`
+// is done, or the evaluation timeout (if set) expires. In that case, a
+// `*TimeoutError` is returned.
+func (p *Parser) FindProxyContext(ctx context.Context, uri string) ([]Proxy, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := p.cachedResult(ctx, u)
+	if ok && entry.proxies != nil {
+		return p.orderByHealth(cloneProxies(entry.proxies)), nil
+	}
+
+	if !ok {
+		result, err := p.evaluate(ctx, uri, u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		entry = &resultCacheEntry{result: result}
+	}
+
+	parsedProxies, err := p.parseProxies(uri, entry.result)
 	if err != nil {
 		return nil, err
 	}
 
+	p.cacheResult(ctx, u, &resultCacheEntry{
+		proxies: cloneProxies(parsedProxies),
+		result:  entry.result,
+	})
+
+	return p.orderByHealth(parsedProxies), nil
+}
+
+// Reorders `proxies` according to their health, if a registry is set.
+func (p *Parser) orderByHealth(proxies []Proxy) []Proxy {
+	if p.proxyHealth == nil {
+		return proxies
+	}
+
+	return p.proxyHealth.Order(proxies)
+}
+
+// Sets the credential of `proxy` from the proxies credentials - if any.
+func (p *Parser) setProxyCredential(proxy Proxy) {
+	parsedProxyURI := proxy.GetURI()
+
+	if parsedProxyURI != nil {
+		cred, ok := p.proxiesCredentials[parsedProxyURI.Host]
+		if ok {
+			proxy.uri.User = url.UserPassword(cred.Username, cred.Password)
+		} else {
+			// NOTE: It deals with localhost synonyms (`127.0.0.1`, and `0.0.0.0`).
+			parsedProxyURIPort := parsedProxyURI.Port()
+
+			if IsLocalhost(parsedProxyURI) {
+				var lCred *credential.BasicAuth
+
+				if cred, ok := p.proxiesCredentials[fmt.Sprintf("127.0.0.1:%s", parsedProxyURIPort)]; ok {
+					lCred = cred
+				}
+
+				if cred, ok := p.proxiesCredentials[fmt.Sprintf("localhost:%s", parsedProxyURIPort)]; lCred == nil && ok {
+					lCred = cred
+				}
+
+				if cred, ok := p.proxiesCredentials[fmt.Sprintf("0.0.0.0:%s", parsedProxyURIPort)]; lCred == nil && ok {
+					lCred = cred
+				}
+
+				if lCred != nil {
+					proxy.uri.User = url.UserPassword(lCred.Username, lCred.Password)
+				}
+			}
+		}
+	}
+}
+
+// Parses `proxiesAsString`, adding credentials - if any.
+func (p *Parser) parseProxies(uri, proxiesAsString string) ([]Proxy, error) {
 	parsedProxies, err := ParseProxy(proxiesAsString)
 	if err != nil {
 		return nil, err
@@ -362,43 +653,13 @@ func (p *Parser) FindProxy(uri string) ([]Proxy, error) {
 	//
 	// TODO: May be move it to `ParseProxy`.
 	for _, parsedProxy := range parsedProxies {
-		parsedProxyURI := parsedProxy.GetURI()
-
-		if parsedProxyURI != nil {
-			cred, ok := p.proxiesCredentials[parsedProxyURI.Host]
-			if ok {
-				parsedProxy.uri.User = url.UserPassword(cred.Username, cred.Password)
-			} else {
-				// NOTE: It deals with localhost synonyms (`127.0.0.1`, and `0.0.0.0`).
-				parsedProxyURIPort := parsedProxyURI.Port()
-
-				if IsLocalhost(parsedProxyURI) {
-					var lCred *credential.BasicAuth
-
-					if cred, ok := p.proxiesCredentials[fmt.Sprintf("127.0.0.1:%s", parsedProxyURIPort)]; ok {
-						lCred = cred
-					}
-
-					if cred, ok := p.proxiesCredentials[fmt.Sprintf("localhost:%s", parsedProxyURIPort)]; lCred == nil && ok {
-						lCred = cred
-					}
-
-					if cred, ok := p.proxiesCredentials[fmt.Sprintf("0.0.0.0:%s", parsedProxyURIPort)]; lCred == nil && ok {
-						lCred = cred
-					}
-
-					if lCred != nil {
-						parsedProxy.uri.User = url.UserPassword(lCred.Username, lCred.Password)
-					}
-				}
-			}
+		if p.strict && !isKnownMode(parsedProxy.GetMode()) {
+			return nil, customerror.NewInvalidError(fmt.Sprintf("PAC proxy mode (%s)", parsedProxy.GetMode()))
 		}
 
-		l.PrintlnfWithOptions(&options.Options{
-			Fields: fields.Fields{
-				"proxy": parsedProxy.GetURI(),
-			},
-		}, level.Debug, "Proxy found for %s", uri)
+		p.setProxyCredential(parsedProxy)
+
+		p.logger.Debug("Proxy found", "url", uri, "proxy", parsedProxy.GetURI())
 	}
 
 	return parsedProxies, nil
@@ -408,9 +669,10 @@ func (p *Parser) FindProxy(uri string) ([]Proxy, error) {
 // Factory.
 //////
 
-// New is able to load PAC from many sources:
-// - Direct: `textOrURI` is the PAC content
+// New is able to load PAC from many sources, guessed in this order:
 // - Remote: `textOrURI` is an HTTP/HTTPS URI
+// - Data: `textOrURI` is a `data:` URI (RFC 2397)
+// - Direct: `textOrURI` is the PAC content - containing `FindProxyForURL`
 // - File: `textOrURI` points to a file:
 //   - As per PAC spec, PAC file should have the `.pac` extension
 //   - Absolute and relative paths are supported
@@ -425,24 +687,191 @@ func (p *Parser) FindProxy(uri string) ([]Proxy, error) {
 //   - URI is: scheme://credential@host/path` where:
 //   - `credential` is `username:password`, and is optional
 //   - `host` is `hostname:port`, and is optional.
+//   - When the source is known, prefer the explicit `NewFromText`,
+//     `NewFromFile`, `NewFromURL`, `NewFromReader`, or `NewFromFS`.
 func New(textOrURI string, proxiesURIs ...string) (*Parser, error) {
-	l = sypl.NewDefault("pacman", level.Info)
+	return NewWithOptions(textOrURI, WithProxiesURIs(proxiesURIs...))
+}
+
+// Creates a Parser with the defaults, and `opts` applied. Content isn't loaded.
+func newParser(opts ...Option) *Parser {
+	p := &Parser{
+		clock:             ClockFunc(time.Now),
+		envVars:           true,
+		httpClient:        http.DefaultClient,
+		logger:            NopLogger(),
+		myIPAddressSource: newDefaultIPAddressSource(),
+		poolSize:          runtime.GOMAXPROCS(0),
+		requestTimeout:    defaultRequestTimeout,
+		retryBackoff:      defaultRetryBackoff,
+		resolver:          &NetResolver{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.opts = opts
+
+	return p
+}
+
+// Checks if `textOrURI` is a remote PAC URI.
+func isURLSource(textOrURI string) bool {
+	return strings.HasPrefix(textOrURI, "http://") || strings.HasPrefix(textOrURI, "https://")
+}
+
+// Checks if `textOrURI` is a `data:` URI.
+func isDataSource(textOrURI string) bool {
+	return len(textOrURI) >= len(dataURIScheme) && strings.EqualFold(textOrURI[:len(dataURIScheme)], dataURIScheme)
+}
+
+// Checks if `textOrURI` is PAC content.
+func isTextSource(textOrURI string) bool {
+	return !isURLSource(textOrURI) && !isDataSource(textOrURI) && strings.Contains(textOrURI, "FindProxyForURL")
+}
+
+// Checks `textOrURI` against the `New` heuristic. URLs, and `data:` URIs -
+// e.g. base64-encoded - are recognized by their scheme, their content is
+// checked once loaded.
+func validateTextOrURI(textOrURI string) error {
+	if isURLSource(textOrURI) || isDataSource(textOrURI) {
+		return nil
+	}
 
 	if err := validation.Get().Var(textOrURI, "pacTextOrURI"); err != nil {
-		return nil, customerror.NewInvalidError("params", customerror.WithError(err))
+		return customerror.NewInvalidError("params", customerror.WithError(err))
 	}
 
+	return nil
+}
+
+// Loads the PAC from `textOrURI`: URL, `data:` URI, text, or file - in that
+// order.
+func (p *Parser) load(textOrURI string) error {
+	switch {
 	// Remote loading.
-	if strings.HasPrefix(textOrURI, "http://") ||
-		strings.HasPrefix(textOrURI, "https://") {
-		return fromURL(textOrURI, proxiesURIs...)
-	}
+	case isURLSource(textOrURI):
+		_, err := p.fromURL(context.Background(), textOrURI)
+
+		return err
+
+	// `data:` URI loading.
+	case isDataSource(textOrURI):
+		return p.fromDataURI(textOrURI)
 
 	// Directly loading.
-	if strings.Contains(textOrURI, "FindProxyForURL") {
-		return fromText(textOrURI, proxiesURIs...)
-	}
+	case isTextSource(textOrURI):
+		return p.fromText(textOrURI)
 
 	// File loading.
-	return fromFile(textOrURI, proxiesURIs...)
+	default:
+		return p.fromFile(textOrURI)
+	}
+}
+
+// NewWithOptions is like `New` but allows to configure the `Parser` with
+// options:
+//   - Loading: `WithHTTPClient`, `WithRoundTripper`, `WithRootCAs`,
+//     `WithClientCertificates`, `WithRequestTimeout`, `WithHeader`,
+//     `WithUserAgent`, `WithRetries`, `WithPACCredential`, `WithCacheDir`,
+//     `WithFallback`, `WithFallbackDirect`
+//   - Credentials: `WithProxiesURIs`, `WithEnvVars`
+//   - Evaluation: `WithPoolSize`, `WithEvaluationTimeout`, `WithStrict`
+//   - Builtins: `WithResolver`, `WithAddressFamily`, `WithClock`,
+//     `WithMyIPAddressSource` (and friends)
+//   - Caching: `WithDNSCache`, `WithResultCache`, `WithResultCacheFullURL`
+//   - Proxies: `WithProxyHealth`
+//   - Debugging: `WithLogger`, `WithTracing`.
+func NewWithOptions(textOrURI string, opts ...Option) (*Parser, error) {
+	if err := validateTextOrURI(textOrURI); err != nil {
+		return nil, err
+	}
+
+	p := newParser(opts...)
+	p.heuristic = true
+
+	if err := p.load(textOrURI); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewFromText creates a Parser from the PAC `text`.
+func NewFromText(text string, opts ...Option) (*Parser, error) {
+	p := newParser(opts...)
+
+	if err := p.fromText(text); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewFromFile creates a Parser from the PAC file `filename`. Absolute, and
+// relative paths, and `file://` URIs are supported.
+func NewFromFile(filename string, opts ...Option) (*Parser, error) {
+	p := newParser(opts...)
+
+	if err := p.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewFromURL creates a Parser from the PAC at `uri`: `http://`, `https://`,
+// `file://`, or `data:` (RFC 2397) URIs.
+func NewFromURL(uri string, opts ...Option) (*Parser, error) {
+	return NewFromURLContext(context.Background(), uri, opts...)
+}
+
+// NewFromURLContext is like `NewFromURL` but loading a remote PAC - retries
+// included - is aborted when `ctx` is done.
+func NewFromURLContext(ctx context.Context, uri string, opts ...Option) (*Parser, error) {
+	p := newParser(opts...)
+
+	var err error
+
+	switch {
+	case isURLSource(uri):
+		_, err = p.fromURL(ctx, uri)
+	case isDataSource(uri):
+		err = p.fromDataURI(uri)
+	case strings.HasPrefix(uri, "file://"):
+		err = p.fromFile(uri)
+	default:
+		err = customerror.NewInvalidError("PAC URI, unsupported scheme")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewFromReader creates a Parser from the PAC read from `r`. `name` is the
+// PAC source, see `Source`.
+func NewFromReader(name string, r io.Reader, opts ...Option) (*Parser, error) {
+	p := newParser(opts...)
+
+	if err := p.fromReader(name, io.NopCloser(r)); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewFromFS creates a Parser from the PAC file `name` of `fsys`, e.g. an
+// `embed.FS`.
+func NewFromFS(fsys fs.FS, name string, opts ...Option) (*Parser, error) {
+	p := newParser(opts...)
+
+	if err := p.fromFS(fsys, name); err != nil {
+		return nil, err
+	}
+
+	return p, nil
 }
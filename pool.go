@@ -0,0 +1,175 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+
+	"github.com/dop251/goja"
+)
+
+// Pre-compiled builtin JS functions. Compiled once, and shared by every engine.
+var builtinJSProgram = goja.MustCompile("builtin_functions.js", builtinJS, false)
+
+// engine is a goja runtime with the builtin natives, builtin JS functions, and
+// the PAC script loaded.
+type engine struct {
+	// Context of the current evaluation. Builtin natives should honor it.
+	ctx context.Context
+
+	// Parser which created the engine.
+	parser *Parser
+
+	// PAC's `FindProxyForURLEx`, or `FindProxyForURL` function, nil if none is
+	// defined.
+	findProxyForURL goja.Callable
+
+	// Trace of the current evaluation, if tracing.
+	trace *Trace
+
+	// Depth of the builtin call being traced.
+	traceDepth int
+
+	vm *goja.Runtime
+}
+
+// Runs `f` on the engine. The JS execution is interrupted if `ctx` is done.
+// The engine is reusable afterwards.
+func (e *engine) run(ctx context.Context, f func(vm *goja.Runtime) (goja.Value, error)) (goja.Value, error) {
+	e.ctx = ctx
+
+	defer func() {
+		e.ctx = context.Background()
+		e.trace = nil
+		e.traceDepth = 0
+	}()
+
+	// Context can't be done, no need to watch it.
+	if ctx.Done() == nil {
+		return f(e.vm)
+	}
+
+	done := make(chan struct{})
+	watcherDone := make(chan struct{})
+
+	go func() {
+		defer close(watcherDone)
+
+		select {
+		case <-ctx.Done():
+			e.vm.Interrupt(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	v, err := f(e.vm)
+
+	close(done)
+
+	// Only clears the interrupt flag once the watcher can't set it anymore.
+	<-watcherDone
+
+	e.vm.ClearInterrupt()
+
+	return v, err
+}
+
+// Creates an engine, and runs the pre-compiled PAC `program` on it. The PAC
+// top-level code is interrupted if `ctx` is done.
+func newEngine(ctx context.Context, p *Parser, program *goja.Program) (*engine, error) {
+	e := &engine{
+		ctx:    context.Background(),
+		parser: p,
+		vm:     goja.New(),
+	}
+
+	e.vm.SetTimeSource(e.now)
+
+	if err := registerBuiltinNatives(e); err != nil {
+		return nil, err
+	}
+
+	if err := registerBuiltinJS(e.vm); err != nil {
+		return nil, err
+	}
+
+	if p.tracing {
+		if err := traceBuiltins(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := e.run(ctx, func(vm *goja.Runtime) (goja.Value, error) {
+		return vm.RunProgram(program)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Looked up once, called with arguments passed as values - never formatted
+	// into JS code. As per the Microsoft IPv6 extensions, `FindProxyForURLEx`
+	// is preferred if defined.
+	findProxyForURL, ok := goja.AssertFunction(e.vm.Get("FindProxyForURLEx"))
+	if !ok {
+		findProxyForURL, _ = goja.AssertFunction(e.vm.Get("FindProxyForURL"))
+	}
+
+	e.findProxyForURL = findProxyForURL
+
+	return e, nil
+}
+
+// enginePool is a bounded pool of engines. Engines are lazily created, up to
+// `size`. Callers block once all engines are in use.
+type enginePool struct {
+	idle    chan *engine
+	parser  *Parser
+	program *goja.Program
+	tokens  chan struct{}
+}
+
+// Gets an idle engine, or creates one - under `ctx` - if none is available.
+// Blocks if the pool is exhausted, until `ctx` is done.
+func (ep *enginePool) get(ctx context.Context) (*engine, error) {
+	select {
+	case ep.tokens <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case e := <-ep.idle:
+		return e, nil
+	default:
+	}
+
+	e, err := newEngine(ctx, ep.parser, ep.program)
+	if err != nil {
+		<-ep.tokens
+
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Returns `e` to the pool.
+func (ep *enginePool) put(e *engine) {
+	select {
+	case ep.idle <- e:
+	default:
+	}
+
+	<-ep.tokens
+}
+
+// Creates a pool of at most `size` engines running `program`.
+func newEnginePool(p *Parser, program *goja.Program, size int) *enginePool {
+	return &enginePool{
+		idle:    make(chan *engine, size),
+		parser:  p,
+		program: program,
+		tokens:  make(chan struct{}, size),
+	}
+}
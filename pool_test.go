@@ -0,0 +1,195 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+const poolTestPAC = `
+function FindProxyForURL(url, host) {
+  if (dnsDomainIs(host, ".internal.com")) return "DIRECT";
+  if (shExpMatch(host, "*.example.com")) return "PROXY 4.5.6.7:8080";
+  return "PROXY 1.2.3.4:8080; DIRECT";
+}
+`
+
+func TestParser_pool_concurrent(t *testing.T) {
+	pac, err := pacman.NewWithOptions(poolTestPAC, pacman.WithPoolSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"http://www.example.com/":      "PROXY 4.5.6.7:8080",
+		"http://app.internal.com/":     "DIRECT",
+		"https://www.saucelabs.com/a/": "PROXY 1.2.3.4:8080; DIRECT",
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for uri, want := range tests {
+				got, err := pac.FindProxyForURL(uri)
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				if got != want {
+					t.Errorf("FindProxyForURL(%s) expected %s, got %s", uri, want, got)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestParser_pool_invalidPAC(t *testing.T) {
+	if _, err := pacman.NewWithOptions("function FindProxyForURL(url, host) { return ; "); err == nil {
+		t.Fatal("Expected syntax error, got nil")
+	}
+
+	if _, err := pacman.NewWithOptions("function FindProxyForURL(url, host) {}; undefinedFunc();"); err == nil {
+		t.Fatal("Expected runtime error, got nil")
+	}
+}
+
+// Resolver answering `init.example.com` only once - so the PAC top-level code
+// behaves differently when engines are lazily created, and blocking on
+// `slow.example.com` until released.
+type engineCreationResolver struct {
+	entered  chan struct{}
+	inits    int32
+	released chan struct{}
+}
+
+func (r *engineCreationResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	switch host {
+	case "init.example.com":
+		if atomic.AddInt32(&r.inits, 1) == 1 {
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		}
+	case "slow.example.com":
+		r.entered <- struct{}{}
+
+		select {
+		case <-r.released:
+		case <-ctx.Done():
+		}
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestParser_pool_engineCreation(t *testing.T) {
+	tests := []struct {
+		name        string
+		topLevel    string
+		wantTimeout bool
+	}{
+		{name: "Should fail - top-level code throwing", topLevel: `throw "boom";`},
+		{name: "Should fail - top-level code interrupted", topLevel: `while (true) {}`, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &engineCreationResolver{
+				entered:  make(chan struct{}),
+				released: make(chan struct{}),
+			}
+
+			pac, err := pacman.NewWithOptions(`
+if (dnsResolve("init.example.com") == null) { `+tt.topLevel+` }
+
+function FindProxyForURL(url, host) {
+  dnsResolve(host);
+
+  return "DIRECT";
+}`,
+				pacman.WithPoolSize(2),
+				pacman.WithResolver(resolver),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Keeps the first engine busy, so the next evaluation creates one.
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				_, _ = pac.FindProxyForURL("http://slow.example.com/")
+			}()
+
+			<-resolver.entered
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			_, err = pac.FindProxyForURLContext(ctx, "http://www.example.com/")
+
+			close(resolver.released)
+			<-done
+
+			var timeoutErr *pacman.TimeoutError
+
+			if got := errors.As(err, &timeoutErr); err == nil || got != tt.wantTimeout {
+				t.Fatalf("Expected timeout %v, got %v", tt.wantTimeout, err)
+			}
+		})
+	}
+}
+
+func benchmarkFindProxyForURLParallel(b *testing.B, opts ...pacman.Option) {
+	b.Helper()
+
+	// Offline: measures evaluations, not the system DNS, nor the network.
+	opts = append([]pacman.Option{
+		pacman.WithResolver(pacman.StaticResolver{"abcdomain.com": {"93.184.216.34"}}),
+		pacman.WithMyIPAddress("192.168.1.2"),
+	}, opts...)
+
+	pac, err := pacman.NewWithOptions("resources/data.pac", opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := pac.FindProxyForURL("http://abcdomain.com/"); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+// Single runtime, all evaluations are serialized - the former lock-based path.
+func BenchmarkFindProxyForURL_locked(b *testing.B) {
+	benchmarkFindProxyForURLParallel(b, pacman.WithPoolSize(1))
+}
+
+// Pool of runtimes, defaults to `GOMAXPROCS`.
+func BenchmarkFindProxyForURL_pooled(b *testing.B) {
+	benchmarkFindProxyForURLParallel(b)
+}
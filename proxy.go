@@ -23,6 +23,16 @@ var validProxySchemesRegex = regexp.MustCompile(`(?m)http|https|socks5|socks|qui
 // Helpers.
 //////
 
+// Checks if `m` is one of the known modes.
+func isKnownMode(m mode.Mode) bool {
+	switch m {
+	case mode.Direct, mode.Proxy, mode.Socks, mode.Socks5:
+		return true
+	default:
+		return false
+	}
+}
+
 func split(source string, s rune) []string {
 	return strings.FieldsFunc(source, func(r rune) bool {
 		return r == s
@@ -76,6 +86,16 @@ func (p *Proxy) String() string {
 	return fmt.Sprintf("%s %s", p.GetMode(), p.GetURI())
 }
 
+// Redacted is like `String`, without the proxy password. Use it for logs, and
+// user output.
+func (p *Proxy) Redacted() string {
+	if p.GetMode() == mode.Direct || p.GetURI() == nil {
+		return p.GetMode().String()
+	}
+
+	return fmt.Sprintf("%s %s", p.GetMode(), p.GetURI().Redacted())
+}
+
 // ParseProxy parses proxy string returned by `FindProxyForURL`, and returns a
 // list of proxies.
 func ParseProxy(pstr string) ([]Proxy, error) {
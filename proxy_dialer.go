@@ -0,0 +1,135 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/saucelabs/pacman/pkg/mode"
+)
+
+// ProxyDialer dials targets through a single proxy. Supported schemes:
+//   - `http`, and `https`, using HTTP CONNECT
+//   - `socks`, and `socks4`: SOCKS4, or SOCKS4a if the target hostname is
+//     resolved by the proxy
+//   - `socks4a`: SOCKS4a
+//   - `socks5`, and `socks5h`: SOCKS5.
+//
+// Credentials are taken from the proxy URI - e.g. set by `ProxiesURIs`. SOCKS4
+// only supports a user ID, the username is used. `DIRECT` dials directly.
+type ProxyDialer struct {
+	// Forward dials the proxy. Default is `net.Dialer`.
+	Forward ContextDialer
+
+	// LocalDNS resolves target hostnames locally, using `Resolver`, instead of
+	// letting the proxy resolve them. Default is remote resolution. SOCKS4a
+	// always resolves remotely.
+	LocalDNS bool
+
+	// Proxy to dial through.
+	Proxy Proxy
+
+	// Resolver used when `LocalDNS` is set. Default is `NetResolver`.
+	Resolver Resolver
+
+	// TLSConfig used to connect to `https` proxies. The server name defaults
+	// to the proxy hostname.
+	TLSConfig *tls.Config
+}
+
+// Dialer returns a `ProxyDialer` dialing through the proxy, with defaults.
+func (p *Proxy) Dialer() *ProxyDialer {
+	return &ProxyDialer{Proxy: *p}
+}
+
+func (d *ProxyDialer) forward() ContextDialer {
+	if d.Forward == nil {
+		return &net.Dialer{}
+	}
+
+	return d.Forward
+}
+
+// Resolves the host of `address` locally. If `ipv4Only` is set, only IPv4
+// addresses are considered.
+func (d *ProxyDialer) resolve(ctx context.Context, address string, ipv4Only bool) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ipv4Only && ip.To4() == nil {
+			return "", fmt.Errorf("SOCKS4 doesn't support IPv6 address %s", host)
+		}
+
+		return address, nil
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = &NetResolver{}
+	}
+
+	ips, err := resolver.LookupIP(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ip := range ips {
+		if !ipv4Only || ip.To4() != nil {
+			return net.JoinHostPort(ip.String(), port), nil
+		}
+	}
+
+	return "", fmt.Errorf("no suitable address found for %s", host)
+}
+
+// DialContext dials `address` through the proxy. It satisfies `ContextDialer`.
+func (d *ProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.Proxy.GetMode() == mode.Direct {
+		return d.forward().DialContext(ctx, network, address)
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("network %s not supported through proxies", network)
+	}
+
+	uri := d.Proxy.GetURI()
+	if uri == nil {
+		return nil, fmt.Errorf("proxy without URI")
+	}
+
+	scheme := strings.ToLower(uri.Scheme)
+
+	// SOCKS4 without remote resolution requires an IPv4 address.
+	socks4 := scheme == "socks" || scheme == "socks4"
+
+	if d.LocalDNS && scheme != "socks4a" {
+		resolved, err := d.resolve(ctx, address, socks4)
+		if err != nil {
+			return nil, err
+		}
+
+		address = resolved
+	}
+
+	switch scheme {
+	case "http", "https":
+		return dialHTTPConnect(ctx, d.forward(), uri, d.TLSConfig, address)
+	case "socks", "socks4", "socks4a":
+		return dialSOCKS(ctx, d.forward(), uri, address, socks4Handshake)
+	case "socks5", "socks5h":
+		return dialSOCKS(ctx, d.forward(), uri, address, socks5Handshake)
+	default:
+		return nil, fmt.Errorf("proxy scheme %s not supported", uri.Scheme)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+func TestProxy_Dialer(t *testing.T) {
+	target := startEchoServer(t, "hello")
+
+	_, port, err := net.SplitHostPort(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolvable by both the proxies (remote DNS), and the resolver (local DNS).
+	hostnameTarget := net.JoinHostPort("localhost", port)
+	resolver := pacman.StaticResolver{"localhost": {"127.0.0.1"}}
+
+	socks4Recorder := &hostRecorder{}
+	socks4Proxy := startSOCKS4Proxy(t, "", socks4Recorder)
+
+	socks4UserRecorder := &hostRecorder{}
+	socks4UserProxy := startSOCKS4Proxy(t, "user", socks4UserRecorder)
+
+	socks5Recorder := &hostRecorder{}
+	socks5Proxy := startRecordingSOCKS5Proxy(t, "user", "pass", socks5Recorder)
+
+	tests := []struct {
+		name        string
+		result      string
+		proxiesURIs []string
+		localDNS    bool
+		recorder    *hostRecorder
+		wantHost    string
+		wantErr     bool
+	}{
+		{
+			name:     "Should work - SOCKS4a, remote DNS",
+			result:   "SOCKS " + socks4Proxy,
+			recorder: socks4Recorder,
+			wantHost: "localhost",
+		},
+		{
+			name:     "Should work - SOCKS4, local DNS",
+			result:   "SOCKS " + socks4Proxy,
+			localDNS: true,
+			recorder: socks4Recorder,
+			wantHost: "127.0.0.1",
+		},
+		{
+			name:        "Should work - SOCKS4 with user ID",
+			result:      "SOCKS " + socks4UserProxy,
+			proxiesURIs: []string{"socks://user:pass@" + socks4UserProxy},
+			recorder:    socks4UserRecorder,
+			wantHost:    "localhost",
+		},
+		{
+			name:     "Should fail - SOCKS4 without user ID",
+			result:   "SOCKS " + socks4UserProxy,
+			recorder: socks4UserRecorder,
+			wantErr:  true,
+		},
+		{
+			name:        "Should work - SOCKS5, remote DNS",
+			result:      "SOCKS5 " + socks5Proxy,
+			proxiesURIs: []string{"socks5://user:pass@" + socks5Proxy},
+			recorder:    socks5Recorder,
+			wantHost:    "localhost",
+		},
+		{
+			name:        "Should work - SOCKS5, local DNS",
+			result:      "SOCKS5 " + socks5Proxy,
+			proxiesURIs: []string{"socks5://user:pass@" + socks5Proxy},
+			localDNS:    true,
+			recorder:    socks5Recorder,
+			wantHost:    "127.0.0.1",
+		},
+		{
+			name:     "Should fail - SOCKS5 without credentials",
+			result:   "SOCKS5 " + socks5Proxy,
+			recorder: socks5Recorder,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pac, err := pacman.New(`function FindProxyForURL(url, host) { return "`+tt.result+`"; }`, tt.proxiesURIs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxies, err := pac.FindProxy("http://" + hostnameTarget + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := proxies[0].Dialer()
+			d.LocalDNS = tt.localDNS
+			d.Resolver = resolver
+
+			var cd pacman.ContextDialer = d
+
+			conn, err := cd.DialContext(context.Background(), "tcp", hostnameTarget)
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+
+					t.Fatal("Expected error, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			readGreeting(t, conn, "hello")
+
+			if got := tt.recorder.Last(); got != tt.wantHost {
+				t.Fatalf("Expected proxy to be asked for %s, got %s", tt.wantHost, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package proxyserver provides a local HTTP, and CONNECT forwarding proxy which
+// routes each request as per a PAC: through the chosen upstream proxies - with
+// failover, and credentials - or directly. It allows tools which only
+// understand `HTTP_PROXY` to work on networks requiring a PAC.
+package proxyserver
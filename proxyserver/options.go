@@ -0,0 +1,33 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package proxyserver
+
+import (
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+// Option allows to configure a `Server`.
+type Option func(s *Server)
+
+// WithAttemptTimeout limits each attempt to connect through an upstream proxy,
+// or directly. Default is 10 seconds.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		if timeout > 0 {
+			s.attemptTimeout = timeout
+		}
+	}
+}
+
+// WithLogger sets the logger of the access logs. Default is a no-op one.
+func WithLogger(logger pacman.Logger) Option {
+	return func(s *Server) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
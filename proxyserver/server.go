@@ -0,0 +1,390 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package proxyserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+const defaultAttemptTimeout = 10 * time.Second
+
+// Hop-by-hop headers, not forwarded. See RFC 7230, section 6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+//////
+// Helpers.
+//////
+
+// Removes hop-by-hop headers, including the ones listed in `Connection`.
+func removeHopByHopHeaders(h http.Header) {
+	for _, field := range h.Values("Connection") {
+		for _, name := range strings.Split(field, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// Describes `proxies` as a PAC result, without credentials.
+func describeAll(proxies []pacman.Proxy) string {
+	s := make([]string, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		s = append(s, proxy.Redacted())
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// Checks if `proxy` is an HTTP proxy, to which plain HTTP requests are
+// forwarded as is.
+func isHTTPProxy(proxy pacman.Proxy) bool {
+	uri := proxy.GetURI()
+	if uri == nil {
+		return false
+	}
+
+	scheme := strings.ToLower(uri.Scheme)
+
+	return scheme == "http" || scheme == "https"
+}
+
+// Access log entry.
+type access struct {
+	decision string
+	err      error
+	hop      string
+	method   string
+	start    time.Time
+	status   int
+	target   string
+}
+
+//////
+// Exported.
+//////
+
+// Server is a forwarding proxy, routing requests as per the PAC. It's an
+// `http.Handler`, serving:
+//   - CONNECT requests, tunneling through the chosen upstream, or directly
+//   - Plain HTTP requests (absolute URI), forwarded to the chosen upstream, or
+//     directly.
+//
+// Upstream proxies are tried in the PAC order, failing over to the next one.
+// Plain HTTP requests with a body aren't retried. Credentials are the ones set
+// in the Parser (`ProxiesURIs`). Failures are reported to the Parser's
+// `ProxyHealth`, if any.
+type Server struct {
+	attemptTimeout time.Duration
+	dialer         *pacman.Dialer
+	logger         pacman.Logger
+	parser         *pacman.Parser
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// Logs the access `a`.
+func (s *Server) log(a *access) {
+	args := []any{
+		"method", a.method,
+		"target", a.target,
+		"decision", a.decision,
+		"hop", a.hop,
+		"status", a.status,
+		"duration", time.Since(a.start).String(),
+	}
+
+	if a.err != nil {
+		args = append(args, "error", a.err.Error())
+
+		s.logger.Warn("access", args...)
+
+		return
+	}
+
+	s.logger.Info("access", args...)
+}
+
+// Returns the transport forwarding plain HTTP requests through `proxy`.
+// Transports are cached, so connections are reused.
+func (s *Server) transport(proxy pacman.Proxy) *http.Transport {
+	key := proxy.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.transports[key]; ok {
+		return t
+	}
+
+	t := &http.Transport{
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		ResponseHeaderTimeout: time.Minute,
+		TLSHandshakeTimeout:   s.attemptTimeout,
+	}
+
+	if isHTTPProxy(proxy) {
+		t.Proxy = http.ProxyURL(proxy.GetURI())
+		t.DialContext = (&net.Dialer{Timeout: s.attemptTimeout}).DialContext
+	} else {
+		d := proxy.Dialer()
+
+		t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, s.attemptTimeout)
+			defer cancel()
+
+			return d.DialContext(ctx, network, address)
+		}
+	}
+
+	s.transports[key] = t
+
+	return t
+}
+
+// ServeHTTP is the `http.Handler` interface implementation.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a := &access{
+		method: r.Method,
+		start:  time.Now(),
+		target: r.Host,
+	}
+
+	defer s.log(a)
+
+	switch {
+	case r.Method == http.MethodConnect:
+		s.serveConnect(w, r, a)
+	case r.URL.IsAbs():
+		a.target = r.URL.Redacted()
+
+		s.serveHTTP(w, r, a)
+	default:
+		a.status = http.StatusBadRequest
+		a.err = errors.New("not a proxy request")
+
+		http.Error(w, "pacman: not a proxy request", a.status)
+	}
+}
+
+// Tunnels CONNECT requests.
+func (s *Server) serveConnect(w http.ResponseWriter, r *http.Request, a *access) {
+	upstream, result, err := s.dialer.DialWithResult(r.Context(), "tcp", r.Host)
+
+	if result != nil {
+		a.decision = describeAll(result.Proxies)
+
+		if hop, ok := result.Hop(); ok {
+			a.hop = hop.Redacted()
+		}
+	}
+
+	if err != nil {
+		a.status = http.StatusBadGateway
+		a.err = err
+
+		http.Error(w, "pacman: "+err.Error(), a.status)
+
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		a.status = http.StatusInternalServerError
+		a.err = errors.New("hijacking not supported")
+
+		http.Error(w, "pacman: hijacking not supported", a.status)
+
+		return
+	}
+
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		a.err = err
+
+		return
+	}
+	defer client.Close()
+
+	a.status = http.StatusOK
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		a.err = err
+
+		return
+	}
+
+	// Data sent by the client before the response.
+	if n := buf.Reader.Buffered(); n > 0 {
+		if _, err := io.CopyN(upstream, buf, int64(n)); err != nil {
+			a.err = err
+
+			return
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		_, _ = io.Copy(upstream, client)
+
+		// Unblocks the copy below.
+		upstream.Close()
+
+		close(done)
+	}()
+
+	_, _ = io.Copy(client, upstream)
+
+	client.Close()
+
+	<-done
+}
+
+// Forwards plain HTTP requests.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request, a *access) {
+	proxies, err := s.parser.FindProxyContext(r.Context(), r.URL.String())
+	if err != nil {
+		a.status = http.StatusBadGateway
+		a.err = err
+
+		http.Error(w, "pacman: "+err.Error(), a.status)
+
+		return
+	}
+
+	a.decision = describeAll(proxies)
+
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+
+	removeHopByHopHeaders(outReq.Header)
+
+	// Requests with a body can't be replayed.
+	retryable := r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+
+	health := s.parser.ProxyHealth()
+
+	var (
+		resp      *http.Response
+		errMsgs   []string
+		targetErr *pacman.TargetError
+	)
+
+	for _, proxy := range proxies {
+		resp, err = s.transport(proxy).RoundTrip(outReq)
+
+		if health != nil {
+			switch {
+			case err == nil:
+				health.ReportSuccess(proxy)
+			case r.Context().Err() == nil && !errors.As(err, &targetErr):
+				health.ReportFailure(proxy)
+			}
+		}
+
+		if err == nil {
+			a.hop = proxy.Redacted()
+
+			break
+		}
+
+		errMsgs = append(errMsgs, proxy.Redacted()+": "+err.Error())
+
+		if !retryable || r.Context().Err() != nil {
+			break
+		}
+	}
+
+	if resp == nil {
+		a.status = http.StatusBadGateway
+		a.err = errors.New(strings.Join(errMsgs, "; "))
+
+		if len(proxies) == 0 {
+			a.err = errors.New("no proxy to try")
+		}
+
+		http.Error(w, "pacman: "+a.err.Error(), a.status)
+
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	a.status = resp.StatusCode
+
+	w.WriteHeader(resp.StatusCode)
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		a.err = err
+	}
+}
+
+// Close closes idle connections to upstreams.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.transports {
+		t.CloseIdleConnections()
+	}
+}
+
+//////
+// Factory.
+//////
+
+// New returns a `Server` routing requests as per `parser`.
+func New(parser *pacman.Parser, opts ...Option) *Server {
+	s := &Server{
+		attemptTimeout: defaultAttemptTimeout,
+		logger:         pacman.NopLogger(),
+		parser:         parser,
+		transports:     map[string]*http.Transport{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.dialer = &pacman.Dialer{
+		AttemptTimeout: s.attemptTimeout,
+		Parser:         parser,
+	}
+
+	return s
+}
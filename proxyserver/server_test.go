@@ -0,0 +1,305 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package proxyserver_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+	"github.com/saucelabs/pacman/proxyserver"
+)
+
+//////
+// Helpers
+//////
+
+// Logger recording the access logs.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []map[string]any
+}
+
+func (l *recordingLogger) record(msg string, args []any) {
+	if msg != "access" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := map[string]any{}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		entry[fmt.Sprint(args[i])] = args[i+1]
+	}
+
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record(msg, args) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record(msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record(msg, args) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record(msg, args) }
+
+// Last returns the last access log entry.
+func (l *recordingLogger) Last() map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return nil
+	}
+
+	return l.entries[len(l.entries)-1]
+}
+
+// Starts an upstream proxy, requiring basic auth `user:pass`. Forwarded
+// responses have the `X-Upstream` header set. Returns its address.
+func startUpstreamProxy(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+
+			return
+		}
+
+		if r.Method != http.MethodConnect {
+			r.RequestURI = ""
+			r.Header.Del("Proxy-Authorization")
+
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+
+				return
+			}
+			defer resp.Body.Close()
+
+			w.Header().Set("X-Upstream", "true")
+			w.WriteHeader(resp.StatusCode)
+
+			_, _ = io.Copy(w, resp.Body)
+
+			return
+		}
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+		defer target.Close()
+
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer client.Close()
+
+		_, _ = io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n")
+
+		go func() {
+			_, _ = io.Copy(target, client)
+
+			target.Close()
+		}()
+
+		_, _ = io.Copy(client, target)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server.Listener.Addr().String()
+}
+
+// Returns the address of a closed port.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := l.Addr().String()
+
+	l.Close()
+
+	return addr
+}
+
+// Starts a `proxyserver.Server` for the PAC `result`, returning its URL.
+func startProxyServer(t *testing.T, result string, logger pacman.Logger, proxiesURIs ...string) *url.URL {
+	t.Helper()
+
+	parser, err := pacman.New(fmt.Sprintf(`function FindProxyForURL(url, host) { return %q; }`, result), proxiesURIs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := proxyserver.New(parser, proxyserver.WithLogger(logger), proxyserver.WithAttemptTimeout(2*time.Second))
+
+	server := httptest.NewServer(proxy)
+
+	t.Cleanup(func() {
+		server.Close()
+		proxy.Close()
+	})
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+//////
+// Test cases
+//////
+
+func TestServer(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer target.Close()
+
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer tlsTarget.Close()
+
+	upstream := startUpstreamProxy(t)
+	dead := closedAddr(t)
+
+	tests := []struct {
+		name         string
+		result       string
+		targetURL    string
+		wantStatus   int
+		wantUpstream bool
+		wantHop      string
+	}{
+		{
+			name:       "Should work - HTTP, DIRECT",
+			result:     "DIRECT",
+			targetURL:  target.URL,
+			wantStatus: http.StatusOK,
+			wantHop:    "DIRECT",
+		},
+		{
+			name:         "Should work - HTTP, failover to upstream with credentials",
+			result:       "PROXY " + dead + "; PROXY " + upstream,
+			targetURL:    target.URL,
+			wantStatus:   http.StatusOK,
+			wantUpstream: true,
+			wantHop:      "PROXY http://user:xxxxx@" + upstream,
+		},
+		{
+			name:       "Should work - CONNECT, DIRECT",
+			result:     "DIRECT",
+			targetURL:  tlsTarget.URL,
+			wantStatus: http.StatusOK,
+			wantHop:    "DIRECT",
+		},
+		{
+			name:       "Should work - CONNECT, failover to upstream with credentials",
+			result:     "PROXY " + dead + "; PROXY " + upstream,
+			targetURL:  tlsTarget.URL,
+			wantStatus: http.StatusOK,
+			wantHop:    "PROXY http://user:xxxxx@" + upstream,
+		},
+		{
+			name:       "Should fail - HTTP, all upstreams down",
+			result:     "PROXY " + dead,
+			targetURL:  target.URL,
+			wantStatus: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+
+			proxyURL := startProxyServer(t, tt.result, logger, "http://user:pass@"+upstream)
+
+			transport := tlsTarget.Client().Transport.(*http.Transport).Clone()
+			transport.Proxy = http.ProxyURL(proxyURL)
+
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(tt.targetURL)
+			if err != nil {
+				if tt.wantStatus == http.StatusOK {
+					t.Fatal(err)
+				}
+
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(body) != "hello" {
+				t.Fatalf("Expected hello, got %s", body)
+			}
+
+			if got := resp.Header.Get("X-Upstream") == "true"; got != tt.wantUpstream {
+				t.Fatalf("Expected forwarded through upstream to be %v, got %v", tt.wantUpstream, got)
+			}
+
+			resp.Body.Close()
+			transport.CloseIdleConnections()
+
+			// CONNECT access logs are emitted once the tunnel is closed.
+			var entry map[string]any
+
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				if entry = logger.Last(); entry != nil {
+					break
+				}
+			}
+
+			if entry == nil {
+				t.Fatal("Expected access log, got none")
+			}
+
+			if entry["hop"] != tt.wantHop {
+				t.Fatalf("Expected hop %s, got %v", tt.wantHop, entry["hop"])
+			}
+
+			decision, _ := entry["decision"].(string)
+			if strings.Contains(decision, "pass") {
+				t.Fatalf("Expected decision to be redacted, got %s", decision)
+			}
+
+			if !strings.HasSuffix(decision, strings.TrimPrefix(tt.wantHop, "PROXY http://user:xxxxx@")) {
+				t.Fatalf("Expected decision to end with the hop, got %s", decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saucelabs/pacman/internal/utils"
+)
+
+const defaultReloadInterval = 5 * time.Minute
+
+// ReloadEvent is notified to the `Reloader` subscribers.
+type ReloadEvent struct {
+	// Err is set if reloading failed. The previous parser is still in use.
+	Err error
+
+	// Parser is the newly loaded parser, nil if reloading failed.
+	Parser *Parser
+
+	// Time of the reload.
+	Time time.Time
+}
+
+// Reloader keeps a `Parser` up to date with its source, polling it on every
+// `Reload` - every interval, see `Run`. Remote PACs are re-fetched - with
+// conditional requests (`If-None-Match`, `If-Modified-Since`), local files are
+// stat'ed, and reloaded if their modification time, or size changed. There's no
+// file system notification: changes are picked up at the next poll. PAC
+// content, and `data:` URIs are never reloaded.
+//
+// A new parser is swapped in - atomically - only if its PAC loaded
+// successfully, otherwise the previous one keeps being used. Evaluations in
+// flight finish with the parser they started with.
+type Reloader struct {
+	current  atomic.Pointer[Parser]
+	interval time.Duration
+	opts     []Option
+	source   string
+
+	// Guards the reload state below, reloads are serialized.
+	mu         sync.Mutex
+	modTime    time.Time
+	size       int64
+	validators validators
+
+	subscribersMu sync.Mutex
+	subscribers   map[int]func(ReloadEvent)
+	nextID        int
+}
+
+//////
+// Helpers.
+//////
+
+// Returns the modification time, and size of the PAC file.
+func statFile(filename string) (time.Time, int64, error) {
+	resolvedFilename, err := utils.FilenameResolver(filename)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	info, err := os.Stat(resolvedFilename)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return info.ModTime(), info.Size(), nil
+}
+
+// Notifies subscribers of `event`. Called without holding any lock, so
+// subscribers can call back the reloader.
+func (r *Reloader) notify(event ReloadEvent) {
+	r.subscribersMu.Lock()
+
+	subscribers := make([]func(ReloadEvent), 0, len(r.subscribers))
+	for _, f := range r.subscribers {
+		subscribers = append(subscribers, f)
+	}
+
+	r.subscribersMu.Unlock()
+
+	for _, f := range subscribers {
+		f(event)
+	}
+}
+
+// Loads the PAC if it changed. Returns nil, if it didn't.
+func (r *Reloader) load(ctx context.Context) (*Parser, error) {
+	p := newParser(r.opts...)
+	p.heuristic = true
+
+	switch {
+	// Initially, the last-known-good PAC is used if loading fails. Afterwards,
+	// the current parser is.
+	case isURLSource(r.source) && r.current.Load() == nil:
+		v, err := p.fromURL(ctx, r.source)
+		if err != nil {
+			return nil, err
+		}
+
+		r.validators = v
+
+	case isURLSource(r.source):
+		v, notModified, err := p.loadURL(ctx, r.source, r.validators)
+		if err != nil {
+			return nil, err
+		}
+
+		if notModified {
+			return nil, nil
+		}
+
+		r.validators = v
+
+	// PAC content, and `data:` URIs never change, they're only loaded once.
+	case isDataSource(r.source) || isTextSource(r.source):
+		if r.current.Load() != nil {
+			return nil, nil
+		}
+
+		if err := p.load(r.source); err != nil {
+			return nil, err
+		}
+
+	default:
+		modTime, size, err := statFile(r.source)
+		if err != nil {
+			return nil, err
+		}
+
+		if modTime.Equal(r.modTime) && size == r.size {
+			return nil, nil
+		}
+
+		if err := p.fromFile(r.source); err != nil {
+			return nil, err
+		}
+
+		r.modTime = modTime
+		r.size = size
+	}
+
+	return p, nil
+}
+
+//////
+// Exported.
+//////
+
+// Parser returns the current parser.
+func (r *Reloader) Parser() *Parser {
+	return r.current.Load()
+}
+
+// FindProxyForURL is like `Parser.FindProxyForURL`, using the current parser.
+func (r *Reloader) FindProxyForURL(uri string) (string, error) {
+	return r.Parser().FindProxyForURL(uri)
+}
+
+// FindProxyForURLContext is like `Parser.FindProxyForURLContext`, using the
+// current parser.
+func (r *Reloader) FindProxyForURLContext(ctx context.Context, uri string) (string, error) {
+	return r.Parser().FindProxyForURLContext(ctx, uri)
+}
+
+// FindProxy is like `Parser.FindProxy`, using the current parser.
+func (r *Reloader) FindProxy(uri string) ([]Proxy, error) {
+	return r.Parser().FindProxy(uri)
+}
+
+// FindProxyContext is like `Parser.FindProxyContext`, using the current
+// parser.
+func (r *Reloader) FindProxyContext(ctx context.Context, uri string) ([]Proxy, error) {
+	return r.Parser().FindProxyContext(ctx, uri)
+}
+
+// Reload checks the source for changes, swapping in a new parser if the PAC
+// changed, and loaded successfully. Returns true if it was swapped in.
+// Subscribers are notified of reloads, and errors.
+func (r *Reloader) Reload(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	p, err := r.load(ctx)
+	r.mu.Unlock()
+
+	if err != nil {
+		r.Parser().logger.Error("Failed to reload PAC", "error", err)
+
+		r.notify(ReloadEvent{Err: err, Time: time.Now()})
+
+		return false, err
+	}
+
+	if p == nil {
+		return false, nil
+	}
+
+	r.current.Store(p)
+
+	p.logger.Debug("PAC reloaded", "source", p.Source())
+
+	r.notify(ReloadEvent{Parser: p, Time: time.Now()})
+
+	return true, nil
+}
+
+// Run reloads the PAC every interval until `ctx` is done. Errors are notified
+// to subscribers, and don't stop it.
+func (r *Reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Errors are notified to subscribers.
+		_, _ = r.Reload(ctx)
+	}
+}
+
+// Subscribe calls `f` on every reload, and reload error. The returned function
+// unsubscribes it.
+func (r *Reloader) Subscribe(f func(event ReloadEvent)) (unsubscribe func()) {
+	r.subscribersMu.Lock()
+	defer r.subscribersMu.Unlock()
+
+	id := r.nextID
+	r.nextID++
+
+	r.subscribers[id] = f
+
+	return func() {
+		r.subscribersMu.Lock()
+		defer r.subscribersMu.Unlock()
+
+		delete(r.subscribers, id)
+	}
+}
+
+//////
+// Factory.
+//////
+
+// NewReloader loads the PAC from `textOrURI` - as `NewWithOptions` does, with
+// `opts`, then `Run` checks it for changes every `interval`. Default interval
+// is 5 minutes.
+func NewReloader(textOrURI string, interval time.Duration, opts ...Option) (*Reloader, error) {
+	if err := validateTextOrURI(textOrURI); err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	r := &Reloader{
+		interval:    interval,
+		opts:        opts,
+		source:      textOrURI,
+		subscribers: make(map[int]func(ReloadEvent)),
+	}
+
+	p, err := r.load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	r.current.Store(p)
+
+	return r, nil
+}
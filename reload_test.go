@@ -0,0 +1,274 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+const (
+	reloadPAC1 = `function FindProxyForURL(url, host) { return "PROXY 127.0.0.1:8080"; }`
+	reloadPAC2 = `function FindProxyForURL(url, host) { return "PROXY 127.0.0.1:8081"; }`
+)
+
+// Serves a PAC with an ETag, recording the `If-None-Match` request headers.
+type pacServer struct {
+	mu          sync.Mutex
+	content     string
+	etag        string
+	ifNoneMatch []string
+}
+
+func (s *pacServer) set(content, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.content = content
+	s.etag = etag
+}
+
+func (s *pacServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ifNoneMatch = append(s.ifNoneMatch, r.Header.Get("If-None-Match"))
+
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Set("ETag", s.etag)
+	_, _ = w.Write([]byte(s.content))
+}
+
+// Finds proxies, e.g. `*pacman.Parser`, and `*pacman.Reloader`.
+type proxyFinder interface {
+	FindProxyForURL(uri string) (string, error)
+}
+
+// Asserts the PAC result for `http://www.example.com/`.
+func assertFindProxyForURL(t *testing.T, f proxyFinder, want string) {
+	t.Helper()
+
+	got, err := f.FindProxyForURL("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestReloader_Reload_url(t *testing.T) {
+	s := &pacServer{}
+	s.set(reloadPAC1, `"v1"`)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := pacman.NewReloader(ts.URL, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []pacman.ReloadEvent
+
+	unsubscribe := r.Subscribe(func(event pacman.ReloadEvent) {
+		events = append(events, event)
+	})
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8080")
+
+	// Not modified.
+	reloaded, err := r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded {
+		t.Fatal("Expected no reload")
+	}
+
+	// Modified.
+	s.set(reloadPAC2, `"v2"`)
+
+	old := r.Parser()
+
+	reloaded, err = r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reloaded {
+		t.Fatal("Expected reload")
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8081")
+
+	// The previous parser is still usable.
+	if got, _ := old.FindProxyForURL("http://www.example.com/"); got != "PROXY 127.0.0.1:8080" {
+		t.Fatalf("Expected the previous parser to be usable, got %s", got)
+	}
+
+	// Invalid, the current parser is kept.
+	s.set("function FindProxyForURL(url, host) {", `"v3"`)
+
+	if _, err := r.Reload(context.Background()); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8081")
+
+	unsubscribe()
+
+	s.set(reloadPAC1, `"v4"`)
+
+	if _, err := r.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].Parser == nil || events[0].Err != nil || events[1].Parser != nil || events[1].Err == nil {
+		t.Fatalf("Expected a reload, and an error events, got %+v", events)
+	}
+
+	want := []string{"", `"v1"`, `"v1"`, `"v2"`, `"v2"`}
+
+	if len(s.ifNoneMatch) != len(want) {
+		t.Fatalf("Expected If-None-Match %q, got %q", want, s.ifNoneMatch)
+	}
+
+	for i := range want {
+		if s.ifNoneMatch[i] != want[i] {
+			t.Fatalf("Expected If-None-Match %q, got %q", want, s.ifNoneMatch)
+		}
+	}
+}
+
+func TestReloader_Reload_file(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "proxy.pac")
+
+	if err := os.WriteFile(filename, []byte(reloadPAC1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pacman.NewReloader(filename, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8080")
+
+	reloaded, err := r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded {
+		t.Fatal("Expected no reload")
+	}
+
+	if err := os.WriteFile(filename, []byte(reloadPAC2+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err = r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reloaded {
+		t.Fatal("Expected reload")
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8081")
+
+	// Removed, the current parser is kept.
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Reload(context.Background()); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8081")
+}
+
+func TestReloader_Reload_dataURI(t *testing.T) {
+	r, err := pacman.NewReloader("data:;base64,"+base64.StdEncoding.EncodeToString([]byte(reloadPAC1)), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8080")
+
+	// Never reloaded.
+	reloaded, err := r.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded {
+		t.Fatal("Expected no reload")
+	}
+}
+
+func TestReloader_Run(t *testing.T) {
+	s := &pacServer{}
+	s.set(reloadPAC1, `"v1"`)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := pacman.NewReloader(ts.URL, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan pacman.ReloadEvent, 1)
+
+	r.Subscribe(func(event pacman.ReloadEvent) {
+		select {
+		case reloaded <- event:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	s.set(reloadPAC2, `"v2"`)
+
+	if event := <-reloaded; event.Err != nil {
+		t.Fatalf("Expected reload, got %v", event.Err)
+	}
+
+	assertFindProxyForURL(t, r, "PROXY 127.0.0.1:8081")
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
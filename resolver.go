@@ -0,0 +1,135 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"net"
+	"sort"
+)
+
+// AddressFamily is the preference of the addresses returned by the DNS builtins
+// (`dnsResolve`, `dnsResolveEx`, `isResolvable`, `isInNet`, etc).
+type AddressFamily int
+
+// List of possible address family preferences.
+const (
+	// AnyFamily keeps the order returned by the resolver.
+	AnyFamily AddressFamily = iota
+
+	// PreferIPv4 moves IPv4 addresses first.
+	PreferIPv4
+
+	// PreferIPv6 moves IPv6 addresses first.
+	PreferIPv6
+
+	// IPv4Only discards IPv6 addresses.
+	IPv4Only
+
+	// IPv6Only discards IPv4 addresses.
+	IPv6Only
+)
+
+// Resolver resolves hostnames to IP addresses.
+type Resolver interface {
+	// LookupIP looks up `host`, returning its IPv4, and IPv6 addresses.
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// NetResolver is a `Resolver` backed by `net.Resolver`. It's the default one.
+type NetResolver struct {
+	// Resolver to use. If nil, `net.DefaultResolver` is used.
+	Resolver *net.Resolver
+}
+
+// LookupIP is the `Resolver` interface implementation.
+func (r *NetResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return resolver.LookupIP(ctx, "ip", host)
+}
+
+// StaticResolver is a map-backed `Resolver`, mapping hostnames to IP literals.
+// IP literals are resolved to themselves. Useful for testing PAC offline.
+type StaticResolver map[string][]string
+
+// LookupIP is the `Resolver` interface implementation.
+func (r StaticResolver) LookupIP(_ context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	ips := []net.IP{}
+
+	for _, address := range r[host] {
+		if ip := net.ParseIP(address); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// Sorts, or filters `ips` according to `family`.
+func applyAddressFamily(ips []net.IP, family AddressFamily) []net.IP {
+	isIPv4 := func(ip net.IP) bool {
+		return ip.To4() != nil
+	}
+
+	switch family {
+	case PreferIPv4, PreferIPv6:
+		sorted := append([]net.IP{}, ips...)
+
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return isIPv4(sorted[i]) == (family == PreferIPv4) && isIPv4(sorted[j]) != (family == PreferIPv4)
+		})
+
+		return sorted
+	case IPv4Only, IPv6Only:
+		filtered := []net.IP{}
+
+		for _, ip := range ips {
+			if isIPv4(ip) == (family == IPv4Only) {
+				filtered = append(filtered, ip)
+			}
+		}
+
+		return filtered
+	default:
+		return ips
+	}
+}
+
+// Looks up `host` using the configured DNS cache, resolver, and address family.
+func (p *Parser) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+
+	var err error
+
+	if p.dnsCache != nil {
+		ips, err = p.dnsCache.lookupIP(ctx, p.resolver, host)
+	} else {
+		ips, err = p.resolver.LookupIP(ctx, host)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ips = applyAddressFamily(ips, p.addressFamily)
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/saucelabs/pacman"
+)
+
+var dualStackResolver = pacman.StaticResolver{
+	"dual.example.com": {"2001:db8::1", "10.1.2.3", "2001:db8::2", "10.1.2.4"},
+	"v6.example.com":   {"2001:db8::1"},
+}
+
+func TestStaticResolver_LookupIP(t *testing.T) {
+	ips, err := dualStackResolver.LookupIP(context.Background(), "dual.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 4 || !ips[1].Equal(net.ParseIP("10.1.2.3")) {
+		t.Fatalf("Expected 4 addresses, got %v", ips)
+	}
+
+	ips, err = dualStackResolver.LookupIP(context.Background(), "192.168.0.1")
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("Expected IP literal to resolve to itself, got %v, %v", ips, err)
+	}
+
+	_, err = dualStackResolver.LookupIP(context.Background(), "unknown.example.com")
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("Expected not found *net.DNSError, got %+v", err)
+	}
+}
+
+func TestNetResolver_LookupIP(t *testing.T) {
+	ips, err := (&pacman.NetResolver{}).LookupIP(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("Expected 127.0.0.1, got %v", ips)
+	}
+}
+
+func TestParser_WithAddressFamily(t *testing.T) {
+	tests := []struct {
+		name       string
+		family     pacman.AddressFamily
+		expression string
+		want       string
+	}{
+		{
+			name:       "Any - dnsResolve",
+			family:     pacman.AnyFamily,
+			expression: `dnsResolve("dual.example.com")`,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "PreferIPv4 - dnsResolve",
+			family:     pacman.PreferIPv4,
+			expression: `dnsResolve("dual.example.com")`,
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "PreferIPv4 - dnsResolveEx",
+			family:     pacman.PreferIPv4,
+			expression: `dnsResolveEx("dual.example.com")`,
+			want:       "10.1.2.3;10.1.2.4;2001:db8::1;2001:db8::2",
+		},
+		{
+			name:       "PreferIPv6 - dnsResolveEx",
+			family:     pacman.PreferIPv6,
+			expression: `dnsResolveEx("dual.example.com")`,
+			want:       "2001:db8::1;2001:db8::2;10.1.2.3;10.1.2.4",
+		},
+		{
+			name:       "IPv4Only - dnsResolveEx",
+			family:     pacman.IPv4Only,
+			expression: `dnsResolveEx("dual.example.com")`,
+			want:       "10.1.2.3;10.1.2.4",
+		},
+		{
+			name:       "IPv4Only - IPv6 only host isn't resolvable",
+			family:     pacman.IPv4Only,
+			expression: `isResolvable("v6.example.com")`,
+			want:       "false",
+		},
+		{
+			name:       "IPv6Only - dnsResolve",
+			family:     pacman.IPv6Only,
+			expression: `dnsResolve("dual.example.com")`,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "isInNet uses the resolver",
+			family:     pacman.PreferIPv4,
+			expression: `isInNet("dual.example.com", "10.0.0.0", "255.0.0.0")`,
+			want:       "true",
+		},
+		{
+			name:       "Unknown host",
+			family:     pacman.AnyFamily,
+			expression: `dnsResolve("unknown.example.com")`,
+			want:       "null",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evalPAC(t, tt.expression,
+				pacman.WithResolver(dualStackResolver),
+				pacman.WithAddressFamily(tt.family),
+			)
+
+			if got != tt.want {
+				t.Fatalf("%s expected %s, got %s", tt.expression, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParser_WithResolver(t *testing.T) {
+	pac, err := pacman.NewWithOptions("resources/data.pac", pacman.WithResolver(pacman.StaticResolver{
+		"www.internal.com": {"10.1.2.3"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := pac.FindProxyForURL("http://www.internal.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r != "DIRECT" {
+		t.Fatalf("Expected DIRECT, got %s", r)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/saucelabs/pacman/internal/lru"
+)
+
+// A cached PAC result. `proxies` is only set once `FindProxy` parsed it.
+type resultCacheEntry struct {
+	proxies []Proxy
+	result  string
+}
+
+// resultCache caches PAC results, for all engines of a Parser.
+type resultCache struct {
+	entries *lru.Cache[string, *resultCacheEntry]
+	fullURL bool
+	ttl     time.Duration
+}
+
+// Returns the cache key of `u`: scheme, and host, or the full URL.
+func (c *resultCache) key(u *url.URL) string {
+	if c.fullURL {
+		return u.String()
+	}
+
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host)
+}
+
+// Checks if results can be cached. Time-dependent PACs, and evaluations with a
+// time override (dry-runs) bypass the cache.
+func (p *Parser) resultCacheable(ctx context.Context) bool {
+	if p.resultCache == nil || p.timeDependent {
+		return false
+	}
+
+	_, overridden := evaluationTimeFromContext(ctx)
+
+	return !overridden
+}
+
+// Returns the cached result for `u`, if any.
+func (p *Parser) cachedResult(ctx context.Context, u *url.URL) (*resultCacheEntry, bool) {
+	if !p.resultCacheable(ctx) {
+		return nil, false
+	}
+
+	return p.resultCache.entries.Get(p.resultCache.key(u))
+}
+
+// Caches the result for `u`, if possible.
+func (p *Parser) cacheResult(ctx context.Context, u *url.URL, entry *resultCacheEntry) {
+	if !p.resultCacheable(ctx) {
+		return
+	}
+
+	p.resultCache.entries.Set(p.resultCache.key(u), entry, p.resultCache.ttl)
+}
+
+// Deep copies `proxies`, so callers can't modify cached ones.
+func cloneProxies(proxies []Proxy) []Proxy {
+	clones := make([]Proxy, len(proxies))
+
+	for i, proxy := range proxies {
+		clones[i] = proxy
+
+		if proxy.uri != nil {
+			uri := *proxy.uri
+			clones[i].uri = &uri
+		}
+	}
+
+	return clones
+}
+
+func newResultCache(ttl time.Duration, maxSize int, fullURL bool) *resultCache {
+	return &resultCache{
+		entries: lru.New[string, *resultCacheEntry](maxSize),
+		fullURL: fullURL,
+		ttl:     ttl,
+	}
+}
+
+//////
+// Exported.
+//////
+
+// ResultCacheStats returns the result cache statistics. Returns zero values if
+// the cache isn't enabled (`WithResultCache`).
+func (p *Parser) ResultCacheStats() CacheStats {
+	if p.resultCache == nil {
+		return CacheStats{}
+	}
+
+	return newCacheStats(p.resultCache.entries.Stats())
+}
+
+// Invalidate removes the cached result for `uri`. Returns true if there was
+// one.
+func (p *Parser) Invalidate(uri string) bool {
+	if p.resultCache == nil {
+		return false
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	return p.resultCache.entries.Delete(p.resultCache.key(u))
+}
+
+// Purge removes all cached results.
+func (p *Parser) Purge() {
+	if p.resultCache != nil {
+		p.resultCache.entries.Purge()
+	}
+}
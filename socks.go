@@ -0,0 +1,327 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// SOCKS4 protocol constants.
+const (
+	socks4Version = 0x04
+
+	socks4CmdConnect = 0x01
+
+	socks4ReplyGranted  = 0x5a
+	socks4ReplyRejected = 0x5b
+)
+
+// SOCKS4 reply codes.
+var socks4Replies = map[byte]string{
+	0x5b: "request rejected, or failed",
+	0x5c: "identd unreachable",
+	0x5d: "identd user ID mismatch",
+}
+
+// SOCKS5 protocol constants.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc1928
+// See: https://datatracker.ietf.org/doc/html/rfc1929
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone             = 0x00
+	socks5AuthUsernamePassword = 0x02
+	socks5AuthNoAcceptable     = 0xff
+
+	socks5AuthUsernamePasswordVersion = 0x01
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrTypeIPv4   = 0x01
+	socks5AddrTypeDomain = 0x03
+	socks5AddrTypeIPv6   = 0x04
+
+	socks5ReplySucceeded = 0x00
+)
+
+// SOCKS5 reply codes.
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5 reply codes about the target, not the proxy.
+var socks5TargetReplies = map[byte]bool{
+	0x02: true,
+	0x03: true,
+	0x04: true,
+	0x05: true,
+	0x06: true,
+}
+
+// Splits `address` (`host:port`) into host, and port number.
+func splitHostPort(address string) (string, uint16, error) {
+	host, portAsString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.ParseUint(portAsString, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", portAsString)
+	}
+
+	return host, uint16(port), nil
+}
+
+// Performs the SOCKS5 handshake over `conn`, asking the server to connect to
+// `address`. Credentials are taken from `proxyURI`, if any.
+func socks5Handshake(conn net.Conn, proxyURI *url.URL, address string) error {
+	host, port, err := splitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	// Greeting.
+	methods := []byte{socks5AuthNone}
+	if proxyURI.User != nil {
+		methods = append(methods, socks5AuthUsernamePassword)
+	}
+
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthUsernamePassword:
+		if proxyURI.User == nil {
+			return errors.New("SOCKS5 server requires authentication")
+		}
+
+		if err := socks5Authenticate(conn, proxyURI.User); err != nil {
+			return err
+		}
+	case socks5AuthNoAcceptable:
+		return errors.New("no acceptable SOCKS5 authentication method")
+	default:
+		return fmt.Errorf("unsupported SOCKS5 authentication method %d", reply[1])
+	}
+
+	// Connect request. Hostnames are resolved by the server.
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("hostname too long %q", host)
+		}
+
+		req = append(req, socks5AddrTypeDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrTypeIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrTypeIPv6)
+		req = append(req, ip.To16()...)
+	}
+
+	req = binary.BigEndian.AppendUint16(req, port)
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	return socks5ReadReply(conn)
+}
+
+// RFC1929 username, and password authentication.
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("SOCKS5 username, or password too long")
+	}
+
+	req := []byte{socks5AuthUsernamePasswordVersion, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[1] != 0x00 {
+		return errors.New("SOCKS5 authentication failed")
+	}
+
+	return nil
+}
+
+// Reads the connect reply, discarding the bound address.
+func socks5ReadReply(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	if header[1] != socks5ReplySucceeded {
+		reason, ok := socks5Replies[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("unknown reply %d", header[1])
+		}
+
+		err := fmt.Errorf("SOCKS5 connect failed: %s", reason)
+
+		if socks5TargetReplies[header[1]] {
+			return &TargetError{Err: err}
+		}
+
+		return err
+	}
+
+	var addrLen int
+
+	switch header[3] {
+	case socks5AddrTypeIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrTypeIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrTypeDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("unsupported SOCKS5 address type %d", header[3])
+	}
+
+	// Address, and port.
+	_, err := io.ReadFull(conn, make([]byte, addrLen+2))
+
+	return err
+}
+
+// Performs the SOCKS4 handshake over `conn`, asking the server to connect to
+// `address`. Hostnames are sent as per SOCKS4a, to be resolved by the server.
+// The username from `proxyURI`, if any, is sent as user ID.
+//
+// See: https://www.openssh.com/txt/socks4.protocol
+// See: https://www.openssh.com/txt/socks4a.protocol
+func socks4Handshake(conn net.Conn, proxyURI *url.URL, address string) error {
+	host, port, err := splitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	req := []byte{socks4Version, socks4CmdConnect}
+	req = binary.BigEndian.AppendUint16(req, port)
+
+	ip := net.ParseIP(host)
+
+	switch {
+	case ip == nil:
+		// SOCKS4a: invalid IP `0.0.0.x`, hostname after the user ID.
+		req = append(req, 0, 0, 0, 1)
+	case ip.To4() != nil:
+		req = append(req, ip.To4()...)
+	default:
+		return fmt.Errorf("SOCKS4 doesn't support IPv6 address %s", host)
+	}
+
+	if proxyURI.User != nil {
+		req = append(req, proxyURI.User.Username()...)
+	}
+
+	req = append(req, 0x00)
+
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0x00)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[1] != socks4ReplyGranted {
+		reason, ok := socks4Replies[reply[1]]
+		if !ok {
+			reason = fmt.Sprintf("unknown reply %d", reply[1])
+		}
+
+		err := fmt.Errorf("SOCKS4 connect failed: %s", reason)
+
+		// Rejected, or failed connecting to the target.
+		if reply[1] == socks4ReplyRejected {
+			return &TargetError{Err: err}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Dials `address` through the SOCKS proxy at `proxyURI`, using `handshake`.
+func dialSOCKS(
+	ctx context.Context,
+	forward ContextDialer,
+	proxyURI *url.URL,
+	address string,
+	handshake func(conn net.Conn, proxyURI *url.URL, address string) error,
+) (net.Conn, error) {
+	conn, err := forward.DialContext(ctx, "tcp", proxyURI.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := withDeadline(ctx, conn, func() error {
+		return handshake(conn, proxyURI, address)
+	}); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
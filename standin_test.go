@@ -0,0 +1,381 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+//////
+// Helpers
+//////
+
+// In-process stand-ins of the servers proxies are tested against.
+
+// Starts a TCP server greeting each client with `greeting`, then echoing.
+// Returns its address.
+func startEchoServer(t *testing.T, greeting string) string {
+	t.Helper()
+
+	return serve(t, func(conn net.Conn) {
+		if _, err := io.WriteString(conn, greeting); err != nil {
+			return
+		}
+
+		_, _ = io.Copy(conn, conn)
+	})
+}
+
+// Returns the address of a closed port.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := l.Addr().String()
+
+	l.Close()
+
+	return addr
+}
+
+// Starts a TCP server handling each connection with `handler`. Returns its
+// address. The server is stopped when the test ends.
+func serve(t *testing.T, handler func(conn net.Conn)) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu    sync.Mutex
+		conns = map[net.Conn]bool{}
+		wg    sync.WaitGroup
+	)
+
+	t.Cleanup(func() {
+		l.Close()
+
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+
+		wg.Wait()
+	})
+
+	// The accept loop counts itself, so handlers are never added to a zero
+	// counter being waited on.
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			conns[conn] = true
+			mu.Unlock()
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				handler(conn)
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// Tunnels `client` to `target`, until one side is done.
+func tunnel(client net.Conn, target string) {
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	go func() {
+		_, _ = io.Copy(upstream, client)
+
+		upstream.Close()
+	}()
+
+	_, _ = io.Copy(client, upstream)
+}
+
+// Starts an HTTP CONNECT proxy. If `proxyAuthorization` isn't empty, requests
+// must have it as `Proxy-Authorization`. Returns its address.
+func startConnectProxy(t *testing.T, proxyAuthorization string) string {
+	t.Helper()
+
+	return serve(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+
+		if req.Method != http.MethodConnect {
+			_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+
+			return
+		}
+
+		if proxyAuthorization != "" && req.Header.Get("Proxy-Authorization") != proxyAuthorization {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+
+			return
+		}
+
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			return
+		}
+
+		tunnel(conn, req.Host)
+	})
+}
+
+// Starts an HTTP CONNECT proxy answering every request with `status`, e.g.
+// "502 Bad Gateway". Returns its address.
+func startFailingConnectProxy(t *testing.T, status string) string {
+	t.Helper()
+
+	return serve(t, func(conn net.Conn) {
+		if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+			return
+		}
+
+		_, _ = io.WriteString(conn, "HTTP/1.1 "+status+"\r\nContent-Length: 0\r\n\r\n")
+	})
+}
+
+// Records the target hosts requested to a stand-in proxy.
+type hostRecorder struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (r *hostRecorder) record(host string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hosts = append(r.hosts, host)
+}
+
+// Last returns the last recorded host.
+func (r *hostRecorder) Last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.hosts) == 0 {
+		return ""
+	}
+
+	return r.hosts[len(r.hosts)-1]
+}
+
+// Starts a SOCKS5 proxy. If `username` isn't empty, username, and password
+// authentication is required. Returns its address.
+func startSOCKS5Proxy(t *testing.T, username, password string) string {
+	t.Helper()
+
+	return startRecordingSOCKS5Proxy(t, username, password, nil)
+}
+
+// Like `startSOCKS5Proxy`, recording the requested hosts to `recorder`.
+func startRecordingSOCKS5Proxy(t *testing.T, username, password string, recorder *hostRecorder) string {
+	t.Helper()
+
+	return serve(t, func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+			return
+		}
+
+		methods := make([]byte, header[1])
+		if _, err := io.ReadFull(conn, methods); err != nil {
+			return
+		}
+
+		want := byte(0x00)
+		if username != "" {
+			want = 0x02
+		}
+
+		offered := false
+
+		for _, m := range methods {
+			offered = offered || m == want
+		}
+
+		if !offered {
+			_, _ = conn.Write([]byte{5, 0xff})
+
+			return
+		}
+
+		if _, err := conn.Write([]byte{5, want}); err != nil {
+			return
+		}
+
+		if username != "" {
+			if !socks5ServerAuth(conn, username, password) {
+				return
+			}
+		}
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		var host string
+
+		switch request[3] {
+		case 0x01, 0x04:
+			ip := make([]byte, 4)
+			if request[3] == 0x04 {
+				ip = make([]byte, 16)
+			}
+
+			if _, err := io.ReadFull(conn, ip); err != nil {
+				return
+			}
+
+			host = net.IP(ip).String()
+		case 0x03:
+			l := make([]byte, 1)
+			if _, err := io.ReadFull(conn, l); err != nil {
+				return
+			}
+
+			name := make([]byte, l[0])
+			if _, err := io.ReadFull(conn, name); err != nil {
+				return
+			}
+
+			host = string(name)
+		default:
+			return
+		}
+
+		port := make([]byte, 2)
+		if _, err := io.ReadFull(conn, port); err != nil {
+			return
+		}
+
+		recorder.record(host)
+
+		target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+		if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			return
+		}
+
+		tunnel(conn, target)
+	})
+}
+
+// Starts a SOCKS4, and SOCKS4a proxy. If `userID` isn't empty, requests must
+// have it. The requested hosts are recorded to `recorder`, if any. Returns its
+// address.
+func startSOCKS4Proxy(t *testing.T, userID string, recorder *hostRecorder) string {
+	t.Helper()
+
+	return serve(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r, header); err != nil || header[0] != 4 || header[1] != 1 {
+			return
+		}
+
+		readString := func() string {
+			s, err := r.ReadString(0)
+			if err != nil {
+				return ""
+			}
+
+			return s[:len(s)-1]
+		}
+
+		if readString() != userID {
+			_, _ = conn.Write([]byte{0, 0x5d, 0, 0, 0, 0, 0, 0})
+
+			return
+		}
+
+		host := net.IP(header[4:8]).String()
+
+		// SOCKS4a.
+		if header[4] == 0 && header[5] == 0 && header[6] == 0 && header[7] != 0 {
+			host = readString()
+		}
+
+		recorder.record(host)
+
+		if _, err := conn.Write([]byte{0, 0x5a, 0, 0, 0, 0, 0, 0}); err != nil {
+			return
+		}
+
+		tunnel(conn, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(header[2:4])))))
+	})
+}
+
+// Server side of the RFC1929 authentication.
+func socks5ServerAuth(conn net.Conn, username, password string) bool {
+	readString := func() string {
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return ""
+		}
+
+		s := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, s); err != nil {
+			return ""
+		}
+
+		return string(s)
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		return false
+	}
+
+	if readString() != username || readString() != password {
+		_, _ = conn.Write([]byte{1, 1})
+
+		return false
+	}
+
+	_, err := conn.Write([]byte{1, 0})
+
+	return err == nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/saucelabs/customerror"
+)
+
+// ErrTracingDisabled is returned by `FindProxyForURLTrace` if the Parser wasn't
+// created with `WithTracing`.
+var ErrTracingDisabled = customerror.NewFailedToError("trace the evaluation, tracing isn't enabled (`WithTracing`)")
+
+// Matches the functions declared by the builtin JS.
+var builtinJSFunctionsRegex = regexp.MustCompile(`(?m)^function ([A-Za-z_]\w*)\(`)
+
+// Names of all builtins: natives, and JS functions. Sorted.
+var builtinNames = func() []string {
+	names := make([]string, 0, len(builtinNatives))
+
+	for name := range builtinNatives {
+		names = append(names, name)
+	}
+
+	for _, match := range builtinJSFunctionsRegex.FindAllStringSubmatch(builtinJS, -1) {
+		names = append(names, match[1])
+	}
+
+	sort.Strings(names)
+
+	return names
+}()
+
+//////
+// Helpers.
+//////
+
+// Exports `v` to a Go value. `undefined` is exported as nil.
+func exportValue(v goja.Value) any {
+	if v == nil {
+		return nil
+	}
+
+	return v.Export()
+}
+
+// Replaces each builtin with a wrapper recording its calls into the engine's
+// trace, if any.
+func traceBuiltins(e *engine) error {
+	for _, name := range builtinNames {
+		name := name
+
+		original, ok := goja.AssertFunction(e.vm.Get(name))
+		if !ok {
+			continue
+		}
+
+		if err := e.vm.Set(name, func(call goja.FunctionCall) goja.Value {
+			if e.trace == nil {
+				return e.callTraced(original, call)
+			}
+
+			args := make([]any, 0, len(call.Arguments))
+			for _, arg := range call.Arguments {
+				args = append(args, exportValue(arg))
+			}
+
+			// Calls are recorded in call order, nested ones after their
+			// caller.
+			index := len(e.trace.Calls)
+
+			e.trace.Calls = append(e.trace.Calls, TraceCall{
+				Args:  args,
+				Depth: e.traceDepth,
+				Name:  name,
+			})
+
+			e.traceDepth++
+
+			start := time.Now()
+
+			defer func() {
+				e.traceDepth--
+
+				e.trace.Calls[index].Duration = time.Since(start)
+
+				if r := recover(); r != nil {
+					e.trace.Calls[index].Error = fmt.Sprint(r)
+
+					panic(r)
+				}
+			}()
+
+			v := e.callTraced(original, call)
+
+			e.trace.Calls[index].Result = exportValue(v)
+
+			return v
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calls the `original` builtin. JS exceptions are re-thrown, interruptions
+// re-issued, so both reach the PAC as if there were no wrapper.
+func (e *engine) callTraced(original goja.Callable, call goja.FunctionCall) goja.Value {
+	v, err := original(call.This, call.Arguments...)
+	if err != nil {
+		var interruptedErr *goja.InterruptedError
+		if errors.As(err, &interruptedErr) {
+			e.vm.Interrupt(interruptedErr.Value())
+
+			return goja.Undefined()
+		}
+
+		panic(err)
+	}
+
+	return v
+}
+
+//////
+// Exported.
+//////
+
+// TraceCall is a builtin call.
+type TraceCall struct {
+	// Args passed to the builtin.
+	Args []any `json:"args"`
+
+	// Depth of the call: 0 if called by the PAC, 1 if called by a builtin
+	// called by the PAC, etc.
+	Depth int `json:"depth"`
+
+	// Duration of the call, including nested calls.
+	Duration time.Duration `json:"duration"`
+
+	// Error thrown, if any.
+	Error string `json:"error,omitempty"`
+
+	// Name of the builtin.
+	Name string `json:"name"`
+
+	// Result returned by the builtin. Nil for `null`, and `undefined`.
+	Result any `json:"result"`
+}
+
+// String formats the call, e.g.: `shExpMatch("a.b.com", "*.b.com") -> true`.
+func (c TraceCall) String() string {
+	args := make([]string, 0, len(c.Args))
+
+	for _, arg := range c.Args {
+		args = append(args, formatTraceValue(arg))
+	}
+
+	result := formatTraceValue(c.Result)
+	if c.Error != "" {
+		result = "throws " + c.Error
+	}
+
+	return fmt.Sprintf("%s(%s) -> %s", c.Name, strings.Join(args, ", "), result)
+}
+
+// Formats `v` as JS would write it.
+func formatTraceValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// Trace of a PAC evaluation: every builtin call, in order, with arguments,
+// results, and durations.
+type Trace struct {
+	// Calls to the builtins, in call order.
+	Calls []TraceCall `json:"calls"`
+
+	// Duration of the evaluation.
+	Duration time.Duration `json:"duration"`
+
+	// Host passed to the PAC.
+	Host string `json:"host"`
+
+	// Result of the PAC.
+	Result string `json:"result"`
+
+	// URL passed to the PAC.
+	URL string `json:"url"`
+}
+
+// String formats the trace, one call per line, indented by depth.
+func (t *Trace) String() string {
+	var b strings.Builder
+
+	for _, call := range t.Calls {
+		fmt.Fprintf(&b, "%s%s (%s)\n", strings.Repeat("  ", call.Depth), call, call.Duration)
+	}
+
+	return b.String()
+}
+
+// FindProxyForURLTrace is like `FindProxyForURLContext`, also returning the
+// trace of the evaluation - even if it failed. The result cache is bypassed.
+// Requires `WithTracing`, otherwise `ErrTracingDisabled` is returned.
+func (p *Parser) FindProxyForURLTrace(ctx context.Context, uri string) (string, *Trace, error) {
+	if !p.tracing {
+		return "", nil, ErrTracingDisabled
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", nil, err
+	}
+
+	trace := &Trace{
+		Calls: []TraceCall{},
+		Host:  u.Hostname(),
+		URL:   uri,
+	}
+
+	start := time.Now()
+
+	result, err := p.evaluate(ctx, uri, u, trace)
+
+	trace.Duration = time.Since(start)
+	trace.Result = result
+
+	return result, trace, err
+}
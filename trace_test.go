@@ -0,0 +1,178 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/pacman"
+)
+
+const traceTestPAC = `
+function FindProxyForURL(url, host) {
+  if (isPlainHostName(host)) return "DIRECT";
+  if (isInNet(host, "10.0.0.0", "255.0.0.0")) return "DIRECT";
+  if (shExpMatch(host, "*.example.com") && myIpAddress() == "192.168.1.2") return "PROXY 4.5.6.7:8080";
+  return "PROXY 1.2.3.4:8080";
+}
+`
+
+func TestParser_FindProxyForURLTrace(t *testing.T) {
+	pac, err := pacman.NewWithOptions(traceTestPAC,
+		pacman.WithTracing(),
+		pacman.WithResolver(pacman.StaticResolver{"intranet.example.com": {"10.1.2.3"}}),
+		pacman.WithMyIPAddress("192.168.1.2"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		uri        string
+		want       string
+		wantCalls  []string
+		wantNested string
+	}{
+		{
+			name: "Should work - nested DNS resolution",
+			uri:  "http://intranet.example.com/",
+			want: "DIRECT",
+			wantCalls: []string{
+				`isPlainHostName("intranet.example.com") -> false`,
+				`isInNet("intranet.example.com", "10.0.0.0", "255.0.0.0") -> true`,
+			},
+			wantNested: `dnsResolve("intranet.example.com") -> "10.1.2.3"`,
+		},
+		{
+			name: "Should work - natives",
+			uri:  "http://www.example.com/",
+			want: "PROXY 4.5.6.7:8080",
+			wantCalls: []string{
+				`isPlainHostName("www.example.com") -> false`,
+				`isInNet("www.example.com", "10.0.0.0", "255.0.0.0") -> false`,
+				`shExpMatch("www.example.com", "*.example.com") -> true`,
+				`myIpAddress() -> "192.168.1.2"`,
+			},
+			wantNested: `dnsResolve("www.example.com") -> null`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, trace, err := pac.FindProxyForURLTrace(context.Background(), tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != tt.want || trace.Result != tt.want {
+				t.Fatalf("Expected %s, got %s (trace: %s)", tt.want, result, trace.Result)
+			}
+
+			if trace.URL != tt.uri || trace.Host != strings.Split(tt.uri, "/")[2] {
+				t.Fatalf("Expected trace of %s, got %s, and %s", tt.uri, trace.URL, trace.Host)
+			}
+
+			calls := []string{}
+			nested := []string{}
+
+			for _, call := range trace.Calls {
+				if call.Depth == 0 {
+					calls = append(calls, call.String())
+				} else {
+					nested = append(nested, call.String())
+				}
+			}
+
+			if strings.Join(calls, "\n") != strings.Join(tt.wantCalls, "\n") {
+				t.Fatalf("Expected calls:\n%s\ngot:\n%s", strings.Join(tt.wantCalls, "\n"), strings.Join(calls, "\n"))
+			}
+
+			found := false
+
+			for _, call := range nested {
+				found = found || call == tt.wantNested
+			}
+
+			if !found {
+				t.Fatalf("Expected nested call %s, got:\n%s", tt.wantNested, strings.Join(nested, "\n"))
+			}
+
+			if !strings.Contains(trace.String(), "\n  "+tt.wantNested+" (") {
+				t.Fatalf("Expected nested call to be indented, got:\n%s", trace.String())
+			}
+		})
+	}
+
+	// Tracing doesn't affect regular evaluations.
+	if r, err := pac.FindProxyForURL("http://intranet/"); err != nil || r != "DIRECT" {
+		t.Fatalf("Expected DIRECT, got %s (%v)", r, err)
+	}
+}
+
+func TestParser_FindProxyForURLTrace_exception(t *testing.T) {
+	pac, err := pacman.NewWithOptions(`
+function FindProxyForURL(url, host) {
+  try {
+    dnsDomainIs(null, ".example.com");
+  } catch (e) {
+    return "DIRECT";
+  }
+
+  return "PROXY 1.2.3.4:8080";
+}`, pacman.WithTracing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, trace, err := pac.FindProxyForURLTrace(context.Background(), "http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != "DIRECT" {
+		t.Fatalf("Expected the exception to be caught by the PAC, got %s", result)
+	}
+
+	if len(trace.Calls) != 1 || trace.Calls[0].Error == "" {
+		t.Fatalf("Expected 1 call with error, got %+v", trace.Calls)
+	}
+}
+
+func TestParser_FindProxyForURLTrace_timeout(t *testing.T) {
+	pac, err := pacman.NewWithOptions(`
+function FindProxyForURL(url, host) {
+  while (true) {
+    isPlainHostName(host);
+  }
+}`, pacman.WithTracing(), pacman.WithEvaluationTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := pac.FindProxyForURLTrace(context.Background(), "http://www.example.com/"); !errors.As(err, new(*pacman.TimeoutError)) {
+			t.Fatalf("Expected TimeoutError, got %v", err)
+		}
+
+		if _, err := pac.FindProxyForURL("http://www.example.com/"); !errors.As(err, new(*pacman.TimeoutError)) {
+			t.Fatalf("Expected TimeoutError, got %v", err)
+		}
+	}
+}
+
+func TestParser_FindProxyForURLTrace_disabled(t *testing.T) {
+	pac, err := pacman.New(traceTestPAC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := pac.FindProxyForURLTrace(context.Background(), "http://www.example.com/"); !errors.Is(err, pacman.ErrTracingDisabled) {
+		t.Fatalf("Expected ErrTracingDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 The pacman Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package pacman
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/pacman/pkg/mode"
+)
+
+// ErrNoSupportedProxy is returned by the `ProxyFunc` function when the PAC only
+// returned proxies not supported by `net/http`, and no `DIRECT`.
+var ErrNoSupportedProxy = customerror.NewFailedToError("find a proxy supported by `net/http` in the PAC result")
+
+// Checks if `net/http` supports the proxy `uri` scheme.
+func isTransportSupported(uri *url.URL) bool {
+	switch strings.ToLower(uri.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+		return true
+	default:
+		return false
+	}
+}
+
+// ProxyFunc returns a function which can be used as `http.Transport.Proxy`.
+// The PAC is evaluated for each request URL, honoring the request context.
+//
+// Policy:
+//   - The first entry supported by `net/http` wins, in the PAC order - as a
+//     `http.Transport` can only use a single proxy per request. See `Dialer`
+//     for failover
+//   - `DIRECT` returns a nil URL, meaning no proxy. So does an empty result
+//   - `PROXY` entries are supported, with `http`, and `https` schemes
+//   - `SOCKS5` entries are supported, as `socks5`
+//   - `SOCKS` (SOCKS4), and `quic` entries aren't supported, thus skipped
+//   - If all entries are skipped, `ErrNoSupportedProxy` is returned instead of
+//     silently connecting directly
+//   - Credentials set when the Parser was created are added to the URL.
+func (p *Parser) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxies, err := p.FindProxyContext(req.Context(), req.URL.String())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, proxy := range proxies {
+			if proxy.GetMode() == mode.Direct {
+				return nil, nil
+			}
+
+			if uri := proxy.GetURI(); uri != nil && isTransportSupported(uri) {
+				return uri, nil
+			}
+		}
+
+		if len(proxies) == 0 {
+			return nil, nil
+		}
+
+		return nil, ErrNoSupportedProxy
+	}
+}